@@ -0,0 +1,139 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error codes, one per phase that can reject a program.
+const (
+	CodeLexical    = "E001"
+	CodeSyntax     = "E002"
+	CodeResolution = "E003"
+	CodeRuntime    = "E004"
+)
+
+// Diagnostic describes one problem in a piece of source text.
+type Diagnostic struct {
+	Code    string
+	Message string
+	// Start and End are byte offsets into the source; End is exclusive.
+	Start int
+	End   int
+	Notes []string
+}
+
+// Diagnoser is implemented by errors that know where in the source they
+// happened.
+type Diagnoser interface {
+	Diagnostics() []Diagnostic
+}
+
+// Render writes d in the style of
+//
+//	error[E002]: Expect expression.
+//	 --> test.lox:2:10
+//	  |
+//	2 | print 1 +;
+//	  |          ^
+//	  = note: ...
+func Render(w io.Writer, name, source string, d Diagnostic) {
+	start := clamp(d.Start, 0, len(source))
+	end := clamp(d.End, start, len(source))
+	if start == len(source) {
+		// point just past the last visible character rather than at an
+		// empty trailing line
+		start = len(strings.TrimRight(source, " \t\r\n"))
+		end = start
+	}
+
+	lineStart := strings.LastIndexByte(source[:start], '\n') + 1
+	lineEnd := strings.IndexByte(source[start:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(source)
+	} else {
+		lineEnd += start
+	}
+	// only underline the first line of a multi-line span
+	if end > lineEnd {
+		end = lineEnd
+	}
+
+	line := strings.Count(source[:start], "\n") + 1
+	column := utf8.RuneCountInString(source[lineStart:start]) + 1
+	text := source[lineStart:lineEnd]
+
+	location := fmt.Sprintf("%d:%d", line, column)
+	if name != "" {
+		location = name + ":" + location
+	}
+	gutter := strings.Repeat(" ", len(strconv.Itoa(line)))
+
+	fmt.Fprintf(w, "error[%s]: %s\n", d.Code, d.Message)
+	fmt.Fprintf(w, "%s--> %s\n", gutter, location)
+	fmt.Fprintf(w, "%s |\n", gutter)
+	fmt.Fprintf(w, "%d | %s\n", line, text)
+	fmt.Fprintf(w, "%s | %s%s\n", gutter, padding(source[lineStart:start]), underline(source[start:end]))
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", gutter, note)
+	}
+}
+
+// RenderAll renders every diagnostic err carries. It reports false if err
+// does not implement Diagnoser.
+func RenderAll(w io.Writer, name, source string, err error) bool {
+	var d Diagnoser
+	if !errors.As(err, &d) {
+		return false
+	}
+	for _, diagnostic := range d.Diagnostics() {
+		Render(w, name, source, diagnostic)
+	}
+	return true
+}
+
+// RenderLocation writes d without a source snippet, for when the text it
+// points into is not at hand. location names where d is, such as
+// "lib.lox:3:10".
+func RenderLocation(w io.Writer, location string, d Diagnostic) {
+	fmt.Fprintf(w, "error[%s]: %s\n", d.Code, d.Message)
+	fmt.Fprintf(w, " --> %s\n", location)
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "  = note: %s\n", note)
+	}
+}
+
+// padding keeps tabs so the caret lines up with the text above it.
+func padding(prefix string) string {
+	builder := strings.Builder{}
+	for _, c := range prefix {
+		if c == '\t' {
+			builder.WriteRune('\t')
+		} else {
+			builder.WriteRune(' ')
+		}
+	}
+	return builder.String()
+}
+
+func underline(span string) string {
+	width := utf8.RuneCountInString(span)
+	if width == 0 {
+		return "^"
+	}
+	return strings.Repeat("^", width)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -2,6 +2,12 @@ module craftinginterpreters/lox
 
 go 1.19
 
-require github.com/samber/lo v1.36.0
+require (
+	github.com/samber/lo v1.36.0
+	golang.org/x/term v0.5.0
+)
 
-require golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17 // indirect
+require (
+	golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+)
@@ -39,8 +39,18 @@ func main() {
 		}{
 			{"Assign", "Name scanner.Token, Value Expr"},
 			{"Binary", "Left Expr, Operator scanner.Token, Right Expr"},
+			{"Call", "Callee Expr, Paren scanner.Token, Arguments []Expr"},
+			{"Get", "Object Expr, Name scanner.Token"},
 			{"Grouping", "Expression Expr"},
-			{"Literal", "Value any"},
+			{"Index", "Object Expr, Bracket scanner.Token, Index Expr"},
+			{"List", "Bracket scanner.Token, Elements []Expr"},
+			{"Literal", "Token scanner.Token, Value any"},
+			{"Logical", "Left Expr, Operator scanner.Token, Right Expr"},
+			{"Map", "Brace scanner.Token, Keys []Expr, Values []Expr"},
+			{"Set", "Object Expr, Name scanner.Token, Value Expr"},
+			{"SetIndex", "Object Expr, Bracket scanner.Token, Index Expr, Value Expr"},
+			{"Super", "Keyword scanner.Token, Method scanner.Token"},
+			{"This", "Keyword scanner.Token"},
 			{"Unary", "Operator scanner.Token, Right Expr"},
 			{"Variable", "Name scanner.Token"},
 		},
@@ -60,10 +70,17 @@ func main() {
 			NodeName      string
 			SubProduction string
 		}{
-			{"Block", "Statements []Stmt"},
+			{"Block", "Brace scanner.Token, Statements []Stmt"},
+			{"Class", "Name scanner.Token, Superclass *Variable, Methods []*Function"},
 			{"Expression", "Expression Expr"},
-			{"Print", "Expression Expr"},
+			{"Function", "Name scanner.Token, Params []scanner.Token, Body []Stmt"},
+			{"If", "Condition Expr, ThenBranch Stmt, ElseBranch Stmt"},
+			{"Print", "Keyword scanner.Token, Expression Expr"},
+			{"Return", "Keyword scanner.Token, Value Expr"},
+			{"Throw", "Keyword scanner.Token, Value Expr"},
+			{"Try", "Keyword scanner.Token, Body []Stmt, Name scanner.Token, Catch []Stmt, Finally []Stmt"},
 			{"Var", "Name scanner.Token, Initializer Expr"},
+			{"While", "Keyword scanner.Token, Condition Expr, Body Stmt"},
 		},
 	}
 	defineAst(outputDir, stmt)
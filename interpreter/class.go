@@ -0,0 +1,46 @@
+package interpreter
+
+var _ LoxCallable = &LoxClass{}
+
+type LoxClass struct {
+	Name       string
+	superclass *LoxClass
+	methods    map[string]*LoxFunction
+}
+
+func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
+	return &LoxClass{
+		Name:       name,
+		superclass: superclass,
+		methods:    methods,
+	}
+}
+
+func (c *LoxClass) findMethod(name string) *LoxFunction {
+	if method, ok := c.methods[name]; ok {
+		return method
+	}
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
+	return nil
+}
+
+func (c *LoxClass) Arity() int {
+	if initializer := c.findMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+func (c *LoxClass) Call(interpreter *Interpreter, arguments []any) any {
+	instance := NewLoxInstance(c)
+	if initializer := c.findMethod("init"); initializer != nil {
+		initializer.bind(instance).Call(interpreter, arguments)
+	}
+	return instance
+}
+
+func (c *LoxClass) String() string {
+	return c.Name
+}
@@ -2,19 +2,24 @@ package interpreter
 
 import (
 	"craftinginterpreters/lox/scanner"
-	"fmt"
 )
 
 type Environment struct {
 	enclosing *Environment
 	values    map[string]any
+	// depth is the number of enclosing environments.
+	depth int
 }
 
 func NewEnv(enclosing *Environment) *Environment {
-	return &Environment{
+	env := &Environment{
 		enclosing: enclosing,
 		values:    map[string]any{},
 	}
+	if enclosing != nil {
+		env.depth = enclosing.depth + 1
+	}
+	return env
 }
 
 func (e *Environment) define(name string, value any) {
@@ -28,7 +33,7 @@ func (e *Environment) get(name scanner.Token) any {
 	if e.enclosing != nil {
 		return e.enclosing.get(name)
 	}
-	panic(fmt.Sprintf("[line %d ]Undefined variable '%s'.", name.Line, name.Lexeme))
+	panic(NewRuntimeError(name, "Undefined variable '%s'.", name.Lexeme))
 }
 
 func (e *Environment) assign(name scanner.Token, value any) {
@@ -40,5 +45,21 @@ func (e *Environment) assign(name scanner.Token, value any) {
 		e.enclosing.assign(name, value)
 		return
 	}
-	panic(fmt.Sprintf("[line %d ]Undefined variable '%s'.", name.Line, name.Lexeme))
+	panic(NewRuntimeError(name, "Undefined variable '%s'.", name.Lexeme))
+}
+
+func (e *Environment) ancestor(distance int) *Environment {
+	env := e
+	for idx := 0; idx < distance; idx++ {
+		env = env.enclosing
+	}
+	return env
+}
+
+func (e *Environment) getAt(distance int, name string) any {
+	return e.ancestor(distance).values[name]
+}
+
+func (e *Environment) assignAt(distance int, name scanner.Token, value any) {
+	e.ancestor(distance).values[name.Lexeme] = value
 }
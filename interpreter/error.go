@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"craftinginterpreters/lox/diagnostics"
+	"craftinginterpreters/lox/scanner"
+	"errors"
+	"fmt"
+)
+
+// RuntimeError is raised while executing a program, at the token whose
+// evaluation failed.
+type RuntimeError struct {
+	Token   scanner.Token
+	Message string
+	// Stack is the Lox call stack when the error was raised, innermost
+	// frame first. It may be empty.
+	Stack []StackFrame
+}
+
+// StackFrame is a Lox function call in progress. Token is where the
+// function was executing: the failing token in the innermost frame and the
+// call into the next frame in the others. File is the script the function
+// was declared in, or "" if it was not read from a file.
+type StackFrame struct {
+	Function string
+	File     string
+	Token    scanner.Token
+}
+
+func (f StackFrame) String() string {
+	if f.File == "" {
+		return fmt.Sprintf("at %s (line %d)", f.Function, f.Token.Line)
+	}
+	return fmt.Sprintf("at %s (%s:%d)", f.Function, f.File, f.Token.Line)
+}
+
+// stackHead and stackTail are the frames FormatStack keeps at each end of a
+// deep stack, such as one left by runaway recursion.
+const (
+	stackHead = 10
+	stackTail = 5
+)
+
+// FormatStack describes stack one frame per line, innermost first, leaving
+// out the middle of stacks too deep to read.
+func FormatStack(stack []StackFrame) []string {
+	if len(stack) <= stackHead+stackTail+1 {
+		return formatFrames(stack)
+	}
+	lines := formatFrames(stack[:stackHead])
+	lines = append(lines, fmt.Sprintf("... %d more frames ...", len(stack)-stackHead-stackTail))
+	return append(lines, formatFrames(stack[len(stack)-stackTail:])...)
+}
+
+func formatFrames(stack []StackFrame) []string {
+	lines := make([]string, 0, len(stack))
+	for _, frame := range stack {
+		lines = append(lines, frame.String())
+	}
+	return lines
+}
+
+func NewRuntimeError(token scanner.Token, format string, args ...any) *RuntimeError {
+	return &RuntimeError{
+		Token:   token,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("[line %s ] %s", e.Token.Start, e.Message)
+}
+
+func (e *RuntimeError) Diagnostics() []diagnostics.Diagnostic {
+	notes := FormatStack(e.Stack)
+	return []diagnostics.Diagnostic{{
+		Code:    diagnostics.CodeRuntime,
+		Message: e.Message,
+		Start:   e.Token.Start.Offset,
+		End:     e.Token.End.Offset,
+		Notes:   notes,
+	}}
+}
+
+// NativeError reports err, returned by a native function, at the call site
+// token. Errors that are already runtime errors keep their own position.
+func NativeError(token scanner.Token, err error) *RuntimeError {
+	var runtimeErr *RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return runtimeErr
+	}
+	return NewRuntimeError(token, "%s", err)
+}
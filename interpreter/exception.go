@@ -0,0 +1,150 @@
+package interpreter
+
+import (
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+)
+
+// LoxError is what a catch clause receives for an error raised by the
+// runtime itself, such as a type error or an undefined variable. Scripts
+// read its message and line properties.
+type LoxError struct {
+	Message string
+	Token   scanner.Token
+	// stack is where the error was raised, kept for when it is rethrown.
+	stack []StackFrame
+}
+
+// Get returns the property called name.
+func (e *LoxError) Get(name string) (any, bool) {
+	switch name {
+	case "message":
+		return e.Message, true
+	case "line":
+		return float64(e.Token.Line), true
+	}
+	return nil, false
+}
+
+func (e *LoxError) String() string {
+	return e.Message
+}
+
+func (e *LoxError) TypeName() string {
+	return "error"
+}
+
+// ThrowError is raised by a throw statement that no catch clause handled.
+// Value is the thrown Lox value.
+type ThrowError struct {
+	RuntimeError
+	Value any
+}
+
+// NewThrowError reports value thrown at token. A rethrown *LoxError keeps
+// the message, position and stack of the original error.
+func NewThrowError(token scanner.Token, value any) *ThrowError {
+	err := &ThrowError{Value: value}
+	if lerr, ok := value.(*LoxError); ok {
+		err.Token = lerr.Token
+		err.Message = lerr.Message
+		err.Stack = lerr.stack
+	} else {
+		err.Token = token
+		err.Message = "Uncaught exception: " + Stringify(value)
+	}
+	return err
+}
+
+// As lets a ThrowError be handled like any other *RuntimeError.
+func (e *ThrowError) As(target any) bool {
+	if t, ok := target.(**RuntimeError); ok {
+		*t = &e.RuntimeError
+		return true
+	}
+	return false
+}
+
+// Caught returns the value a catch clause receives for err, and false if
+// err can't be caught. Exceeded limits can't be, so that a script cannot
+// ignore them.
+func Caught(err any) (any, bool) {
+	switch e := err.(type) {
+	case *ThrowError:
+		return e.Value, true
+	case *RuntimeError:
+		return &LoxError{Message: e.Message, Token: e.Token, stack: e.Stack}, true
+	}
+	return nil, false
+}
+
+func (i *Interpreter) VisitThrowStmt(t *parser.Throw) any {
+	err := NewThrowError(t.Keyword, i.evaluateExpr(t.Value))
+	if err.Stack == nil {
+		err.Stack = i.stackTrace(err.Token)
+	}
+	panic(err)
+}
+
+func (i *Interpreter) VisitTryStmt(t *parser.Try) any {
+	pending := i.protect(t.Body, NewEnv(i.env))
+	if pending != nil && t.Catch != nil {
+		if value, ok := Caught(pending); ok {
+			env := NewEnv(i.env)
+			env.define(t.Name.Lexeme, value)
+			pending = i.protect(t.Catch, env)
+		}
+	}
+
+	if t.Finally != nil {
+		switch pending.(type) {
+		case nil, returnValue, *ThrowError, *RuntimeError:
+			// an error in the finally clause replaces the pending one
+			i.executeBlock(t.Finally, NewEnv(i.env))
+		}
+	}
+	if pending != nil {
+		panic(pending)
+	}
+	return nil
+}
+
+// protect executes a block and returns whatever it panicked with.
+func (i *Interpreter) protect(statements []parser.Stmt, env *Environment) (terr any) {
+	defer func() {
+		terr = recover()
+	}()
+	i.executeBlock(statements, env)
+	return nil
+}
+
+// stackTrace returns the Lox call stack, innermost first, with token as the
+// position in the innermost frame.
+func (i *Interpreter) stackTrace(token scanner.Token) []StackFrame {
+	stack := make([]StackFrame, 0, len(i.calls)+1)
+	for idx := len(i.calls) - 1; idx >= 0; idx-- {
+		frame := i.calls[idx]
+		stack = append(stack, StackFrame{Function: frame.function, File: frame.file, Token: token})
+		token = frame.token
+	}
+	return append(stack, StackFrame{Function: "script", File: i.sourceName, Token: token})
+}
+
+// annotate records the call stack on a runtime error the first time it
+// unwinds through a call, while the frames it was raised in still exist.
+func (i *Interpreter) annotate(terr any) {
+	var err *RuntimeError
+	switch rerr := terr.(type) {
+	case *RuntimeError:
+		err = rerr
+	case *LimitError:
+		err = &rerr.RuntimeError
+	case *ThrowError:
+		err = &rerr.RuntimeError
+	default:
+		return
+	}
+	if err.Stack == nil {
+		err.Stack = i.stackTrace(err.Token)
+	}
+}
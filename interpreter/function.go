@@ -0,0 +1,92 @@
+package interpreter
+
+import (
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+	"fmt"
+)
+
+type LoxCallable interface {
+	Arity() int
+	Call(interpreter *Interpreter, arguments []any) any
+}
+
+var _ LoxCallable = &LoxFunction{}
+
+type LoxFunction struct {
+	declaration   *parser.Function
+	closure       *Environment
+	isInitializer bool
+	// file is the script the function was declared in.
+	file string
+}
+
+func NewLoxFunction(declaration *parser.Function, closure *Environment, isInitializer bool, file string) *LoxFunction {
+	return &LoxFunction{
+		declaration:   declaration,
+		closure:       closure,
+		isInitializer: isInitializer,
+		file:          file,
+	}
+}
+
+// bind returns a copy of the method whose closure defines "this" as instance.
+func (f *LoxFunction) bind(instance *LoxInstance) *LoxFunction {
+	env := NewEnv(f.closure)
+	env.define("this", instance)
+	return NewLoxFunction(f.declaration, env, f.isInitializer, f.file)
+}
+
+func (f *LoxFunction) Arity() int {
+	return len(f.declaration.Params)
+}
+
+func (f *LoxFunction) Call(interpreter *Interpreter, arguments []any) (result any) {
+	env := NewEnv(f.closure)
+	for idx, param := range f.declaration.Params {
+		env.define(param.Lexeme, arguments[idx])
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if v, ok := r.(returnValue); ok {
+				result = v.value
+				if f.isInitializer {
+					result = f.closure.getAt(0, "this")
+				}
+				return
+			}
+			panic(r)
+		}
+	}()
+	interpreter.executeBlock(f.declaration.Body, env)
+	if f.isInitializer {
+		return f.closure.getAt(0, "this")
+	}
+	return nil
+}
+
+func (f *LoxFunction) String() string {
+	return fmt.Sprintf("<fn %s>", f.declaration.Name.Lexeme)
+}
+
+// returnValue unwinds the Go stack from a return statement back to the
+// enclosing LoxFunction.Call.
+type returnValue struct {
+	value any
+}
+
+// newCall records a call to callable made at token. A class is named after
+// its initializer, as the VM names it.
+func newCall(callable LoxCallable, token scanner.Token) call {
+	switch c := callable.(type) {
+	case *LoxFunction:
+		return call{function: c.declaration.Name.Lexeme, file: c.file, token: token}
+	case *LoxClass:
+		if initializer := c.findMethod("init"); initializer != nil {
+			return call{function: "init", file: initializer.file, token: token}
+		}
+		return call{function: c.Name, token: token}
+	}
+	return call{function: "native", token: token}
+}
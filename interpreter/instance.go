@@ -0,0 +1,35 @@
+package interpreter
+
+import (
+	"craftinginterpreters/lox/scanner"
+)
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]any
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{
+		class:  class,
+		fields: map[string]any{},
+	}
+}
+
+func (l *LoxInstance) get(name scanner.Token) any {
+	if v, ok := l.fields[name.Lexeme]; ok {
+		return v
+	}
+	if method := l.class.findMethod(name.Lexeme); method != nil {
+		return method.bind(l)
+	}
+	panic(NewRuntimeError(name, "Undefined property '%s'.", name.Lexeme))
+}
+
+func (l *LoxInstance) set(name scanner.Token, value any) {
+	l.fields[name.Lexeme] = value
+}
+
+func (l *LoxInstance) String() string {
+	return l.class.Name + " instance"
+}
@@ -1,37 +1,189 @@
 package interpreter
 
 import (
+	"context"
 	"craftinginterpreters/lox/parser"
 	"craftinginterpreters/lox/scanner"
-	"errors"
 	"fmt"
+	"io"
+	"os"
 )
 
 var _ parser.ExprVisitor = &Interpreter{}
 var _ parser.StmtVisitor = &Interpreter{}
 
 type Interpreter struct {
-	env *Environment
+	globals *Environment
+	env     *Environment
+	locals  map[parser.Expr]int
+	stdout  io.Writer
+
+	limits  Limits
+	caps    Sandbox
+	ctx     context.Context
+	limited bool
+	steps   int
+	loop    *parser.While
+	// sourceName is the file being run, recorded in functions it declares
+	// and in stack traces.
+	sourceName string
+	// calls are the Lox functions being executed, innermost last.
+	calls []call
+}
+
+// call records a function call for stack traces and the call depth limit.
+type call struct {
+	function string
+	file     string
+	token    scanner.Token
 }
 
+// NewInterpreter returns an interpreter for trusted scripts, with every
+// built-in module installed and io working on the host's stdin and files.
 func NewInterpreter() *Interpreter {
+	i := newInterpreter()
+	for _, module := range Modules() {
+		i.InstallModule(module)
+	}
+	i.InstallIO(IO{Stdin: os.Stdin, FS: OSFileSystem{}})
+	return i
+}
+
+func newInterpreter() *Interpreter {
+	globals := NewEnv(nil)
 	return &Interpreter{
-		env: NewEnv(nil),
+		globals: globals,
+		env:     globals,
+		locals:  map[parser.Expr]int{},
+		stdout:  os.Stdout,
+		limits:  Limits{MaxCallDepth: DefaultMaxCallDepth},
+		ctx:     context.Background(),
 	}
 }
 
-func (i *Interpreter) Interpret(statements []parser.Stmt) (err error) {
+// SetStdout redirects the output of print statements.
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.stdout = w
+}
+
+// SetSourceName names the file the following scripts come from, for stack
+// traces. The default "" means they were not read from a file.
+func (i *Interpreter) SetSourceName(name string) {
+	i.sourceName = name
+}
+
+func (i *Interpreter) Interpret(statements []parser.Stmt) error {
+	return i.InterpretContext(context.Background(), statements)
+}
+
+// InterpretContext is like Interpret but stops with a *LimitError once ctx
+// is done or the script exceeds the limits set by SetLimits.
+func (i *Interpreter) InterpretContext(ctx context.Context, statements []parser.Stmt) (err error) {
+	defer i.begin(ctx)()
+	defer func() {
+		err = i.recoverError(recover())
+	}()
+	for _, statement := range statements {
+		i.evaluateStmt(statement)
+	}
+	return nil
+}
+
+// Eval is like Interpret but returns the value of the last statement if it
+// is an expression statement.
+func (i *Interpreter) Eval(statements []parser.Stmt) (value any, err error) {
+	defer i.begin(context.Background())()
 	defer func() {
 		if terr := recover(); terr != nil {
-			err = errors.New(terr.(string))
+			value = nil
+			err = i.recoverError(terr)
 		}
 	}()
-	for _, statement := range statements {
+	for idx, statement := range statements {
+		if last, ok := statement.(*parser.Expression); ok && idx == len(statements)-1 {
+			if i.limited {
+				i.step(last)
+			}
+			return i.evaluateExpr(last.Expression), nil
+		}
 		i.evaluateStmt(statement)
 	}
+	return nil, nil
+}
+
+// Evaluate evaluates a single resolved expression in the global scope.
+func (i *Interpreter) Evaluate(expr parser.Expr) (value any, err error) {
+	defer i.begin(context.Background())()
+	defer func() {
+		if terr := recover(); terr != nil {
+			value = nil
+			err = i.recoverError(terr)
+		}
+	}()
+	return i.evaluateExpr(expr), nil
+}
+
+func (i *Interpreter) recoverError(terr any) error {
+	if terr == nil {
+		return nil
+	}
+	i.annotate(terr)
+	switch rerr := terr.(type) {
+	case *RuntimeError:
+		return rerr
+	case *LimitError:
+		return rerr
+	case *ThrowError:
+		return rerr
+	}
+	// a Go panic here is a bug in the interpreter, not in the script
+	return fmt.Errorf("internal interpreter error: %v", terr)
+}
+
+// DefineGlobal binds name in the global environment, replacing any existing
+// binding.
+func (i *Interpreter) DefineGlobal(name string, value any) {
+	i.globals.define(name, value)
+}
+
+// DefineNative binds name to a Go function that takes Lox values directly.
+// An arity of -1 accepts any number of arguments.
+func (i *Interpreter) DefineNative(name string, arity int, fn func(arguments []any) (any, error)) {
+	i.globals.define(name, NewNativeFunction(name, arity, fn))
+}
+
+// RegisterFunc binds name to an ordinary Go function, converting arguments
+// and results between Lox and Go values as described by WrapFunc.
+func (i *Interpreter) RegisterFunc(name string, fn any) error {
+	native, err := WrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	i.globals.define(name, native)
 	return nil
 }
 
+// Global returns the value bound to name in the global environment.
+func (i *Interpreter) Global(name string) (any, bool) {
+	value, ok := i.globals.values[name]
+	return value, ok
+}
+
+// Globals returns a copy of the bindings in the global environment.
+func (i *Interpreter) Globals() map[string]any {
+	res := make(map[string]any, len(i.globals.values))
+	for name, value := range i.globals.values {
+		res[name] = value
+	}
+	return res
+}
+
+// Resolve records the number of environments between expr and the
+// declaration of the variable it refers to.
+func (i *Interpreter) Resolve(expr parser.Expr, depth int) {
+	i.locals[expr] = depth
+}
+
 func (i *Interpreter) VisitLiteralExpr(l *parser.Literal) any {
 	return l.Value
 }
@@ -40,6 +192,21 @@ func (i *Interpreter) VisitGroupingExpr(e *parser.Grouping) any {
 	return i.evaluateExpr(e.Expression)
 }
 
+func (i *Interpreter) VisitLogicalExpr(l *parser.Logical) any {
+	left := i.evaluateExpr(l.Left)
+
+	if l.Operator.Type == scanner.OR {
+		if i.isTruthy(left) {
+			return left
+		}
+	} else {
+		if !i.isTruthy(left) {
+			return left
+		}
+	}
+	return i.evaluateExpr(l.Right)
+}
+
 func (i *Interpreter) VisitUnaryExpr(u *parser.Unary) any {
 	right := i.evaluateExpr(u.Right)
 
@@ -47,6 +214,7 @@ func (i *Interpreter) VisitUnaryExpr(u *parser.Unary) any {
 	case scanner.BANG:
 		return !i.isTruthy(right)
 	case scanner.MINUS:
+		i.checkNumberOperand(u.Operator, right)
 		return -right.(float64)
 	}
 
@@ -92,10 +260,12 @@ func (i *Interpreter) VisitBinaryExpr(b *parser.Binary) any {
 		_, ok1 = left.(string)
 		_, ok2 = right.(string)
 		if ok1 && ok2 {
-			return left.(string) + right.(string)
+			res := left.(string) + right.(string)
+			i.checkStringLength(b.Operator, res)
+			return res
 		}
 
-		panic(fmt.Sprintf("[line %d ], Operands must be two number or strings.", b.Operator.Line))
+		panic(NewRuntimeError(b.Operator, "Operands must be two number or strings."))
 	case scanner.SLASH:
 		i.checkNumberOperand(b.Operator, left, right)
 		return left.(float64) / right.(float64)
@@ -110,8 +280,143 @@ func (i *Interpreter) VisitBinaryExpr(b *parser.Binary) any {
 	return nil
 }
 
+func (i *Interpreter) VisitCallExpr(c *parser.Call) any {
+	callee := i.evaluateExpr(c.Callee)
+
+	arguments := make([]any, 0, len(c.Arguments))
+	for _, argument := range c.Arguments {
+		arguments = append(arguments, i.evaluateExpr(argument))
+	}
+
+	function, ok := callee.(LoxCallable)
+	if !ok {
+		panic(NewRuntimeError(c.Paren, "Can only call functions and classes."))
+	}
+	if function.Arity() >= 0 && len(arguments) != function.Arity() {
+		panic(NewRuntimeError(c.Paren, "Expected %d arguments but got %d.", function.Arity(), len(arguments)))
+	}
+	if native, ok := function.(*NativeFunction); ok {
+		result, err := native.Invoke(arguments)
+		if err != nil {
+			panic(NativeError(c.Paren, err))
+		}
+		i.checkNativeResult(c.Paren, arguments, result)
+		return result
+	}
+	if i.limits.MaxCallDepth > 0 && len(i.calls) >= i.limits.MaxCallDepth {
+		panic(NewLimitError(c.Paren, ErrCallDepth))
+	}
+	i.calls = append(i.calls, newCall(function, c.Paren))
+	defer func() {
+		if terr := recover(); terr != nil {
+			i.annotate(terr)
+			i.calls = i.calls[:len(i.calls)-1]
+			panic(terr)
+		}
+		i.calls = i.calls[:len(i.calls)-1]
+	}()
+	return function.Call(i, arguments)
+}
+
+func (i *Interpreter) VisitListExpr(l *parser.List) any {
+	elements := make([]any, 0, len(l.Elements))
+	for _, element := range l.Elements {
+		elements = append(elements, i.evaluateExpr(element))
+	}
+	i.checkCollectionSize(l.Bracket, len(elements))
+	return NewLoxList(elements)
+}
+
+func (i *Interpreter) VisitMapExpr(m *parser.Map) any {
+	res := NewLoxMap()
+	for idx := range m.Keys {
+		key := i.evaluateExpr(m.Keys[idx])
+		value := i.evaluateExpr(m.Values[idx])
+		if err := res.Set(key, value); err != nil {
+			panic(NewRuntimeError(m.Brace, "%s", err))
+		}
+	}
+	i.checkCollectionSize(m.Brace, res.Len())
+	return res
+}
+
+func (i *Interpreter) VisitIndexExpr(e *parser.Index) any {
+	object := i.evaluateExpr(e.Object)
+	index := i.evaluateExpr(e.Index)
+	value, err := GetIndex(object, index)
+	if err != nil {
+		panic(NewRuntimeError(e.Bracket, "%s", err))
+	}
+	return value
+}
+
+func (i *Interpreter) VisitSetIndexExpr(s *parser.SetIndex) any {
+	object := i.evaluateExpr(s.Object)
+	index := i.evaluateExpr(s.Index)
+	value := i.evaluateExpr(s.Value)
+	if err := SetIndex(object, index, value); err != nil {
+		panic(NewRuntimeError(s.Bracket, "%s", err))
+	}
+	if m, ok := object.(*LoxMap); ok {
+		i.checkCollectionSize(s.Bracket, m.Len())
+	}
+	return value
+}
+
+func (i *Interpreter) VisitGetExpr(g *parser.Get) any {
+	object := i.evaluateExpr(g.Object)
+	switch o := object.(type) {
+	case *LoxInstance:
+		return o.get(g.Name)
+	case *LoxError:
+		if value, ok := o.Get(g.Name.Lexeme); ok {
+			return value
+		}
+		panic(NewRuntimeError(g.Name, "Undefined property '%s'.", g.Name.Lexeme))
+	}
+	panic(NewRuntimeError(g.Name, "Only instances have properties."))
+}
+
+func (i *Interpreter) VisitSetExpr(s *parser.Set) any {
+	object := i.evaluateExpr(s.Object)
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(NewRuntimeError(s.Name, "Only instances have fields."))
+	}
+	value := i.evaluateExpr(s.Value)
+	if _, ok := instance.fields[s.Name.Lexeme]; !ok {
+		i.checkCollectionSize(s.Name, len(instance.fields)+1)
+	}
+	instance.set(s.Name, value)
+	return value
+}
+
+func (i *Interpreter) VisitSuperExpr(s *parser.Super) any {
+	distance := i.locals[s]
+	superclass := i.env.getAt(distance, "super").(*LoxClass)
+	// "this" is always bound one environment inside the "super" environment.
+	object := i.env.getAt(distance-1, "this").(*LoxInstance)
+
+	method := superclass.findMethod(s.Method.Lexeme)
+	if method == nil {
+		panic(NewRuntimeError(s.Method, "Undefined property '%s'.", s.Method.Lexeme))
+	}
+	return method.bind(object)
+}
+
+func (i *Interpreter) VisitThisExpr(t *parser.This) any {
+	return i.lookUpVariable(t.Keyword, t)
+}
+
 func (i *Interpreter) VisitVariableExpr(v *parser.Variable) any {
-	return i.env.get(v.Name)
+	return i.lookUpVariable(v.Name, v)
+}
+
+func (i *Interpreter) lookUpVariable(name scanner.Token, expr parser.Expr) any {
+	if distance, ok := i.locals[expr]; ok {
+		return i.env.getAt(distance, name.Lexeme)
+	}
+	return i.globals.get(name)
 }
 
 func (i *Interpreter) isEqual(left, right any) bool {
@@ -123,13 +428,16 @@ func (i *Interpreter) evaluateExpr(expr parser.Expr) any {
 }
 
 func (i *Interpreter) evaluateStmt(expr parser.Stmt) any {
+	if i.limited {
+		i.step(expr)
+	}
 	return expr.Accept(i)
 }
 
 func (i *Interpreter) checkNumberOperand(operator scanner.Token, objects ...any) {
 	for _, object := range objects {
 		if _, ok := object.(float64); !ok {
-			panic(fmt.Sprintf("[line %d ], Operand must be a number.", operator.Line))
+			panic(NewRuntimeError(operator, "Operand must be a number."))
 		}
 	}
 }
@@ -139,12 +447,85 @@ func (i *Interpreter) VisitExpressionStmt(e *parser.Expression) any {
 	return nil
 }
 
+func (i *Interpreter) VisitClassStmt(c *parser.Class) any {
+	var superclass *LoxClass
+	if c.Superclass != nil {
+		class, ok := i.evaluateExpr(c.Superclass).(*LoxClass)
+		if !ok {
+			panic(NewRuntimeError(c.Superclass.Name, "Superclass must be a class."))
+		}
+		superclass = class
+	}
+
+	i.env.define(c.Name.Lexeme, nil)
+
+	if superclass != nil {
+		i.env = NewEnv(i.env)
+		i.env.define("super", superclass)
+	}
+
+	methods := map[string]*LoxFunction{}
+	for _, method := range c.Methods {
+		methods[method.Name.Lexeme] = NewLoxFunction(method, i.env, method.Name.Lexeme == "init", i.sourceName)
+	}
+
+	class := NewLoxClass(c.Name.Lexeme, superclass, methods)
+
+	if superclass != nil {
+		i.env = i.env.enclosing
+	}
+	i.env.assign(c.Name, class)
+	return nil
+}
+
+func (i *Interpreter) VisitFunctionStmt(f *parser.Function) any {
+	function := NewLoxFunction(f, i.env, false, i.sourceName)
+	i.env.define(f.Name.Lexeme, function)
+	return nil
+}
+
+func (i *Interpreter) VisitIfStmt(s *parser.If) any {
+	if i.isTruthy(i.evaluateExpr(s.Condition)) {
+		i.evaluateStmt(s.ThenBranch)
+	} else if s.ElseBranch != nil {
+		i.evaluateStmt(s.ElseBranch)
+	}
+	return nil
+}
+
+func (i *Interpreter) VisitWhileStmt(w *parser.While) any {
+	if i.limited {
+		outer := i.loop
+		i.loop = w
+		defer func() {
+			i.loop = outer
+		}()
+	}
+	for i.isTruthy(i.evaluateExpr(w.Condition)) {
+		i.evaluateStmt(w.Body)
+		// each further iteration counts as a step so that loops with an
+		// empty body are still stopped at the loop
+		if i.limited {
+			i.step(w)
+		}
+	}
+	return nil
+}
+
 func (i *Interpreter) VisitPrintStmt(p *parser.Print) any {
 	value := i.evaluateExpr(p.Expression)
-	fmt.Println(value)
+	fmt.Fprintln(i.stdout, value)
 	return nil
 }
 
+func (i *Interpreter) VisitReturnStmt(r *parser.Return) any {
+	var value any
+	if r.Value != nil {
+		value = i.evaluateExpr(r.Value)
+	}
+	panic(returnValue{value})
+}
+
 func (i *Interpreter) VisitVarStmt(v *parser.Var) any {
 	var value any
 	if v.Initializer != nil {
@@ -156,7 +537,11 @@ func (i *Interpreter) VisitVarStmt(v *parser.Var) any {
 
 func (i *Interpreter) VisitAssignExpr(a *parser.Assign) any {
 	value := i.evaluateExpr(a.Value)
-	i.env.assign(a.Name, value)
+	if distance, ok := i.locals[a]; ok {
+		i.env.assignAt(distance, a.Name, value)
+	} else {
+		i.globals.assign(a.Name, value)
+	}
 	return value
 }
 
@@ -171,6 +556,7 @@ func (i *Interpreter) executeBlock(statements []parser.Stmt, env *Environment) {
 		i.env = parentEnv
 	}()
 	i.env = env
+	i.checkEnvDepth(statements, env)
 	for _, statement := range statements {
 		i.evaluateStmt(statement)
 	}
@@ -0,0 +1,184 @@
+package interpreter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// FileSystem is a filesystem scripts can also write to.
+type FileSystem interface {
+	fs.FS
+	WriteFile(name string, data []byte) error
+	AppendFile(name string, data []byte) error
+}
+
+// OSFileSystem is the host's filesystem. Paths are the host's own, relative
+// to the working directory.
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFileSystem) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0o666)
+}
+
+func (OSFileSystem) AppendFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// noFileSystem is the FS of an io module given none: every file operation
+// fails.
+type noFileSystem struct{}
+
+var errNoFileSystem = errors.New("no filesystem")
+
+func (noFileSystem) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errNoFileSystem}
+}
+
+// IO is where the io module reads and writes. A nil Stdin reads nothing, a
+// nil Stdout discards output, a nil FS makes every file operation fail, and
+// an FS that is not a FileSystem is read-only.
+type IO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	FS     fs.FS
+}
+
+// IOModule returns the io module working on config.
+func IOModule(config IO) *Module {
+	if config.Stdin == nil {
+		config.Stdin = strings.NewReader("")
+	}
+	if config.Stdout == nil {
+		config.Stdout = io.Discard
+	}
+	if config.FS == nil {
+		config.FS = noFileSystem{}
+	}
+	stdin := bufio.NewReader(config.Stdin)
+	writable := func(op, name string) (FileSystem, error) {
+		switch fsys := config.FS.(type) {
+		case noFileSystem:
+			return nil, ioError(op, name, errNoFileSystem)
+		case FileSystem:
+			return fsys, nil
+		}
+		return nil, ioError(op, name, errors.New("filesystem is read-only"))
+	}
+
+	return &Module{
+		Name: "io",
+		Functions: []*NativeFunction{
+			mustWrap("readLine", func() (any, error) {
+				line, err := stdin.ReadString('\n')
+				if err == io.EOF && line == "" {
+					return nil, nil
+				}
+				if err != nil && err != io.EOF {
+					return nil, fmt.Errorf("Cannot read line: %v.", err)
+				}
+				line = strings.TrimSuffix(line, "\n")
+				return strings.TrimSuffix(line, "\r"), nil
+			}),
+			mustWrap("write", func(s string) error {
+				if _, err := io.WriteString(config.Stdout, s); err != nil {
+					return fmt.Errorf("Cannot write: %v.", err)
+				}
+				return nil
+			}),
+			mustWrap("readFile", func(name string) (string, error) {
+				data, err := fs.ReadFile(config.FS, name)
+				if err != nil {
+					return "", ioError("read", name, err)
+				}
+				return string(data), nil
+			}),
+			mustWrap("writeFile", func(name, data string) error {
+				fsys, err := writable("write", name)
+				if err != nil {
+					return err
+				}
+				if err := fsys.WriteFile(name, []byte(data)); err != nil {
+					return ioError("write", name, err)
+				}
+				return nil
+			}),
+			mustWrap("appendFile", func(name, data string) error {
+				fsys, err := writable("append to", name)
+				if err != nil {
+					return err
+				}
+				if err := fsys.AppendFile(name, []byte(data)); err != nil {
+					return ioError("append to", name, err)
+				}
+				return nil
+			}),
+			mustWrap("listDir", func(name string) (*LoxList, error) {
+				entries, err := fs.ReadDir(config.FS, name)
+				if err != nil {
+					return nil, ioError("list", name, err)
+				}
+				names := make([]any, len(entries))
+				for idx, entry := range entries {
+					names[idx] = entry.Name()
+				}
+				return NewLoxList(names), nil
+			}),
+			mustWrap("exists", func(name string) (bool, error) {
+				_, err := fs.Stat(config.FS, name)
+				if errors.Is(err, fs.ErrNotExist) {
+					return false, nil
+				}
+				if err != nil {
+					return false, ioError("check", name, err)
+				}
+				return true, nil
+			}),
+		},
+	}
+}
+
+// stdoutWriter writes wherever the interpreter's print statements go, even
+// after SetStdout redirects them.
+type stdoutWriter struct {
+	interpreter *Interpreter
+}
+
+func (w stdoutWriter) Write(p []byte) (int, error) {
+	return w.interpreter.stdout.Write(p)
+}
+
+// InstallIO installs the io module working on config, whose nil Stdout
+// means the interpreter's own stdout.
+func (i *Interpreter) InstallIO(config IO) {
+	if config.Stdout == nil {
+		config.Stdout = stdoutWriter{i}
+	}
+	i.InstallModule(IOModule(config))
+}
+
+// ioError describes a failed file operation without repeating the Go
+// operation and path that *fs.PathError adds.
+func ioError(op, name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Errorf("Cannot %s '%s': %v.", op, name, err)
+}
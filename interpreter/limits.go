@@ -0,0 +1,203 @@
+package interpreter
+
+import (
+	"context"
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+	"errors"
+	"time"
+)
+
+// Limits bounds the work a single Interpret call may do. Zero fields are
+// unlimited, except MaxCallDepth.
+type Limits struct {
+	// MaxSteps is the number of statements that may be executed; each loop
+	// iteration also counts as one.
+	MaxSteps int
+	// MaxCallDepth is the number of nested Lox function calls. Zero or less
+	// means DefaultMaxCallDepth, on the VM as well: there is no unlimited
+	// depth, since running out of Go stack cannot be recovered from.
+	MaxCallDepth int
+	// Timeout is the wall-clock time the script may run for.
+	Timeout time.Duration
+}
+
+var (
+	ErrStepLimit = errors.New("step limit exceeded")
+	ErrCallDepth = errors.New("call depth exceeded")
+)
+
+// checkInterval is how many statements run between checks of the context,
+// which are too costly to make on every statement.
+const checkInterval = 1024
+
+// LimitError is the runtime error raised when a script exceeds its Limits or
+// the caps of its Sandbox, or its context is done. Err is one of the Err
+// variables of this package or the error of the context.
+type LimitError struct {
+	RuntimeError
+	Err error
+}
+
+// NewLimitError reports at token that err, one of the Err variables of this
+// package or a context error, stopped the script.
+func NewLimitError(token scanner.Token, err error) *LimitError {
+	message := "Execution canceled."
+	switch err {
+	case ErrStepLimit:
+		message = "Step limit exceeded."
+	case ErrCallDepth:
+		message = "Maximum call depth exceeded."
+	case ErrStringLength:
+		message = "String length limit exceeded."
+	case ErrCollectionSize:
+		message = "Collection size limit exceeded."
+	case ErrEnvDepth:
+		message = "Environment depth limit exceeded."
+	case context.DeadlineExceeded:
+		message = "Execution timed out."
+	}
+	return &LimitError{
+		RuntimeError: RuntimeError{Token: token, Message: message},
+		Err:          err,
+	}
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// As lets a LimitError be handled like any other *RuntimeError.
+func (e *LimitError) As(target any) bool {
+	if t, ok := target.(**RuntimeError); ok {
+		*t = &e.RuntimeError
+		return true
+	}
+	return false
+}
+
+// SetLimits sets the limits applied to each later Interpret call.
+func (i *Interpreter) SetLimits(limits Limits) {
+	if limits.MaxCallDepth <= 0 {
+		limits.MaxCallDepth = DefaultMaxCallDepth
+	}
+	i.limits = limits
+}
+
+// begin prepares the budget for one run and returns a function that
+// releases it.
+func (i *Interpreter) begin(ctx context.Context) func() {
+	cancel := func() {}
+	if i.limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, i.limits.Timeout)
+	}
+	i.ctx = ctx
+	i.steps = 0
+	i.calls = i.calls[:0]
+	i.loop = nil
+	i.limited = ctx.Done() != nil || i.limits.MaxSteps > 0
+	return cancel
+}
+
+// step counts one executed statement against the budget.
+func (i *Interpreter) step(stmt parser.Stmt) {
+	i.steps++
+	if i.limits.MaxSteps > 0 && i.steps > i.limits.MaxSteps {
+		panic(NewLimitError(i.stepToken(stmt), ErrStepLimit))
+	}
+	if i.steps%checkInterval == 0 {
+		if err := i.ctx.Err(); err != nil {
+			panic(NewLimitError(i.stepToken(stmt), err))
+		}
+	}
+}
+
+// stepToken returns the token to report a limit at stmt at.
+func (i *Interpreter) stepToken(stmt parser.Stmt) scanner.Token {
+	return i.orEnclosing(stmtToken(stmt))
+}
+
+// orEnclosing returns token, or if it is missing, as for an empty statement
+// list, the token of the innermost loop or call being executed.
+func (i *Interpreter) orEnclosing(token scanner.Token) scanner.Token {
+	if token.Start.Line != 0 {
+		return token
+	}
+	if i.loop != nil {
+		return i.loop.Keyword
+	}
+	if len(i.calls) > 0 {
+		return i.calls[len(i.calls)-1].token
+	}
+	return token
+}
+
+// stmtToken returns a token to report errors in stmt at.
+func stmtToken(stmt parser.Stmt) scanner.Token {
+	switch s := stmt.(type) {
+	case *parser.Block:
+		if len(s.Statements) > 0 {
+			return stmtToken(s.Statements[0])
+		}
+		return s.Brace
+	case *parser.Class:
+		return s.Name
+	case *parser.Expression:
+		return exprToken(s.Expression)
+	case *parser.Function:
+		return s.Name
+	case *parser.If:
+		return exprToken(s.Condition)
+	case *parser.Print:
+		return s.Keyword
+	case *parser.Return:
+		return s.Keyword
+	case *parser.Throw:
+		return s.Keyword
+	case *parser.Try:
+		return s.Keyword
+	case *parser.Var:
+		return s.Name
+	case *parser.While:
+		return s.Keyword
+	}
+	return scanner.Token{}
+}
+
+func exprToken(expr parser.Expr) scanner.Token {
+	switch e := expr.(type) {
+	case *parser.Assign:
+		return e.Name
+	case *parser.Binary:
+		return e.Operator
+	case *parser.Call:
+		return e.Paren
+	case *parser.Get:
+		return e.Name
+	case *parser.Grouping:
+		return exprToken(e.Expression)
+	case *parser.Index:
+		return e.Bracket
+	case *parser.List:
+		return e.Bracket
+	case *parser.Literal:
+		return e.Token
+	case *parser.Logical:
+		return e.Operator
+	case *parser.Map:
+		return e.Brace
+	case *parser.Set:
+		return e.Name
+	case *parser.SetIndex:
+		return e.Bracket
+	case *parser.Super:
+		return e.Keyword
+	case *parser.This:
+		return e.Keyword
+	case *parser.Unary:
+		return e.Operator
+	case *parser.Variable:
+		return e.Name
+	}
+	return scanner.Token{}
+}
@@ -0,0 +1,197 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoxList is an ordered, growable collection of Lox values.
+type LoxList struct {
+	Elements []any
+}
+
+func NewLoxList(elements []any) *LoxList {
+	return &LoxList{Elements: elements}
+}
+
+func (l *LoxList) String() string {
+	return formatCollection(l)
+}
+
+func (l *LoxList) TypeName() string {
+	return "list"
+}
+
+// formatCollection formats a list or map, quoting the strings in it to tell
+// them apart from other values. A collection that contains itself prints as
+// [...] or {...}. It keeps its own stack instead of recursing, so that no
+// nesting a script can build runs the host out of Go stack.
+func formatCollection(root any) string {
+	// the work stack holds what is left to write, next on top: values to
+	// format, text to copy, and the collections to leave once done
+	type (
+		text  string
+		leave struct{ collection any }
+	)
+	var sb strings.Builder
+	seen := map[any]bool{}
+	stack := []any{root}
+	for len(stack) > 0 {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch v := item.(type) {
+		case text:
+			sb.WriteString(string(v))
+		case leave:
+			delete(seen, v.collection)
+		case string:
+			sb.WriteString(strconv.Quote(v))
+		case *LoxList:
+			if seen[v] {
+				sb.WriteString("[...]")
+				continue
+			}
+			seen[v] = true
+			stack = append(stack, leave{v}, text("]"))
+			for idx := len(v.Elements) - 1; idx >= 0; idx-- {
+				stack = append(stack, v.Elements[idx])
+				if idx > 0 {
+					stack = append(stack, text(", "))
+				}
+			}
+			stack = append(stack, text("["))
+		case *LoxMap:
+			if seen[v] {
+				sb.WriteString("{...}")
+				continue
+			}
+			seen[v] = true
+			stack = append(stack, leave{v}, text("}"))
+			for idx := len(v.keys) - 1; idx >= 0; idx-- {
+				key := v.keys[idx]
+				stack = append(stack, v.values[key], text(": "), key)
+				if idx > 0 {
+					stack = append(stack, text(", "))
+				}
+			}
+			stack = append(stack, text("{"))
+		default:
+			sb.WriteString(Stringify(v))
+		}
+	}
+	return sb.String()
+}
+
+// Get returns the element at index.
+func (l *LoxList) Get(index any) (any, error) {
+	idx, err := l.index(index, len(l.Elements))
+	if err != nil {
+		return nil, err
+	}
+	return l.Elements[idx], nil
+}
+
+// Set replaces the element at index.
+func (l *LoxList) Set(index, value any) error {
+	idx, err := l.index(index, len(l.Elements))
+	if err != nil {
+		return err
+	}
+	l.Elements[idx] = value
+	return nil
+}
+
+// index converts a Lox number into a position below limit.
+func (l *LoxList) index(value any, limit int) (int, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("List index must be a number, not %s.", TypeName(value))
+	}
+	if n != math.Trunc(n) {
+		return 0, fmt.Errorf("List index must be an integer.")
+	}
+	if n < 0 || n >= float64(limit) {
+		return 0, fmt.Errorf("List index %s out of range for length %d.", Stringify(n), len(l.Elements))
+	}
+	return int(n), nil
+}
+
+func (l *LoxList) push(value any) {
+	l.Elements = append(l.Elements, value)
+}
+
+func (l *LoxList) pop() (any, error) {
+	if len(l.Elements) == 0 {
+		return nil, fmt.Errorf("Can't pop from an empty list.")
+	}
+	value := l.Elements[len(l.Elements)-1]
+	l.Elements = l.Elements[:len(l.Elements)-1]
+	return value, nil
+}
+
+func (l *LoxList) insert(index float64, value any) error {
+	// inserting at the length appends
+	idx, err := l.index(index, len(l.Elements)+1)
+	if err != nil {
+		return err
+	}
+	l.Elements = append(l.Elements, nil)
+	copy(l.Elements[idx+1:], l.Elements[idx:])
+	l.Elements[idx] = value
+	return nil
+}
+
+func (l *LoxList) remove(index float64) (any, error) {
+	idx, err := l.index(index, len(l.Elements))
+	if err != nil {
+		return nil, err
+	}
+	value := l.Elements[idx]
+	l.Elements = append(l.Elements[:idx], l.Elements[idx+1:]...)
+	return value, nil
+}
+
+// slice returns a new list of the elements from start up to end, which
+// defaults to the length of the list.
+func (l *LoxList) slice(start int, end ...int) (*LoxList, error) {
+	stop := len(l.Elements)
+	if len(end) > 1 {
+		return nil, fmt.Errorf("Expected at most 3 arguments but got %d.", len(end)+2)
+	}
+	if len(end) == 1 {
+		stop = end[0]
+	}
+	if start < 0 || stop > len(l.Elements) || start > stop {
+		return nil, fmt.Errorf("Slice [%d, %d) out of range for length %d.", start, stop, len(l.Elements))
+	}
+	elements := make([]any, stop-start)
+	copy(elements, l.Elements[start:stop])
+	return NewLoxList(elements), nil
+}
+
+// sort orders a list of numbers or a list of strings in place.
+func (l *LoxList) sort() error {
+	numbers, strs := true, true
+	for _, element := range l.Elements {
+		_, isNumber := element.(float64)
+		_, isString := element.(string)
+		numbers = numbers && isNumber
+		strs = strs && isString
+	}
+	switch {
+	case numbers:
+		sort.SliceStable(l.Elements, func(a, b int) bool {
+			return l.Elements[a].(float64) < l.Elements[b].(float64)
+		})
+	case strs:
+		sort.SliceStable(l.Elements, func(a, b int) bool {
+			return l.Elements[a].(string) < l.Elements[b].(string)
+		})
+	default:
+		return fmt.Errorf("Can only sort a list of numbers or a list of strings.")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// LoxMap maps keys to values, keeping its entries in insertion order so that
+// it prints and iterates the same way every time. Keys are strings, numbers,
+// booleans or nil, compared like the == operator does; NaN is rejected.
+type LoxMap struct {
+	keys   []any
+	values map[any]any
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{values: map[any]any{}}
+}
+
+// Len returns the number of entries.
+func (m *LoxMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys in insertion order.
+func (m *LoxMap) Keys() []any {
+	res := make([]any, len(m.keys))
+	copy(res, m.keys)
+	return res
+}
+
+// Get returns the value for key, or nil if there is none.
+func (m *LoxMap) Get(key any) (any, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	return m.values[key], nil
+}
+
+// Set binds key to value, keeping the position of an existing key.
+func (m *LoxMap) Set(key, value any) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+	return nil
+}
+
+func (m *LoxMap) has(key any) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	_, ok := m.values[key]
+	return ok, nil
+}
+
+// delete removes key and reports whether it was there.
+func (m *LoxMap) delete(key any) (bool, error) {
+	if ok, err := m.has(key); !ok || err != nil {
+		return false, err
+	}
+	delete(m.values, key)
+	for idx, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:idx], m.keys[idx+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+func (m *LoxMap) keyList() *LoxList {
+	return NewLoxList(m.Keys())
+}
+
+func (m *LoxMap) valueList() *LoxList {
+	values := make([]any, len(m.keys))
+	for idx, key := range m.keys {
+		values[idx] = m.values[key]
+	}
+	return NewLoxList(values)
+}
+
+// entryList returns the entries as a list of [key, value] pairs.
+func (m *LoxMap) entryList() *LoxList {
+	entries := make([]any, len(m.keys))
+	for idx, key := range m.keys {
+		entries[idx] = NewLoxList([]any{key, m.values[key]})
+	}
+	return NewLoxList(entries)
+}
+
+func (m *LoxMap) String() string {
+	return formatCollection(m)
+}
+
+func (m *LoxMap) TypeName() string {
+	return "map"
+}
+
+func checkKey(key any) error {
+	switch k := key.(type) {
+	case float64:
+		// NaN is not equal to itself, so an entry under it could never be
+		// found again
+		if math.IsNaN(k) {
+			return errors.New("Map key cannot be NaN.")
+		}
+		return nil
+	case nil, bool, string:
+		return nil
+	}
+	return fmt.Errorf("Map key must be a string, number, boolean or nil, not %s.", TypeName(key))
+}
+
+// GetIndex evaluates object[index] for a list or map.
+func GetIndex(object, index any) (any, error) {
+	switch o := object.(type) {
+	case *LoxList:
+		return o.Get(index)
+	case *LoxMap:
+		return o.Get(index)
+	}
+	return nil, fmt.Errorf("Only lists and maps can be indexed.")
+}
+
+// SetIndex evaluates object[index] = value for a list or map.
+func SetIndex(object, index, value any) error {
+	switch o := object.(type) {
+	case *LoxList:
+		return o.Set(index, value)
+	case *LoxMap:
+		return o.Set(index, value)
+	}
+	return fmt.Errorf("Only lists and maps can be indexed.")
+}
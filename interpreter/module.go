@@ -0,0 +1,103 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Module is a named group of native functions that a host installs into the
+// globals together, such as everything that reads the clock.
+type Module struct {
+	Name      string
+	Functions []*NativeFunction
+}
+
+// SafeModules are the built-in modules that give no access to the host, fit
+// for any sandbox.
+var SafeModules = []string{"core", "math", "random"}
+
+// modules builds each built-in module afresh, so that modules with state,
+// like the random number generator, are not shared between interpreters.
+var modules = []struct {
+	name string
+	new  func() *Module
+}{
+	{"core", coreModule},
+	{"math", mathModule},
+	{"random", func() *Module {
+		return RandomModule(rand.NewSource(time.Now().UnixNano()))
+	}},
+	{"time", timeModule},
+	{"env", envModule},
+	// io has no streams or files of its own; hosts install it with
+	// InstallIO to grant them
+	{"io", func() *Module {
+		return IOModule(IO{})
+	}},
+}
+
+// Modules returns every built-in module.
+func Modules() []*Module {
+	res := make([]*Module, 0, len(modules))
+	for _, module := range modules {
+		res = append(res, module.new())
+	}
+	return res
+}
+
+// LookupModule returns the built-in module called name.
+func LookupModule(name string) (*Module, bool) {
+	for _, module := range modules {
+		if module.name == name {
+			return module.new(), true
+		}
+	}
+	return nil, false
+}
+
+// InstallModule defines the functions of module as globals.
+func (i *Interpreter) InstallModule(module *Module) {
+	for _, function := range module.Functions {
+		i.globals.define(function.Name, function)
+	}
+}
+
+func timeModule() *Module {
+	return &Module{
+		Name: "time",
+		Functions: []*NativeFunction{
+			NewNativeFunction("clock", 0, func(arguments []any) (any, error) {
+				return float64(time.Now().UnixNano()) / float64(time.Second), nil
+			}),
+		},
+	}
+}
+
+func envModule() *Module {
+	return &Module{
+		Name: "env",
+		Functions: []*NativeFunction{
+			NewNativeFunction("getenv", 1, func(arguments []any) (any, error) {
+				name, ok := arguments[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("Argument 1 to 'getenv' must be a string, not %s.", TypeName(arguments[0]))
+				}
+				if value, ok := os.LookupEnv(name); ok {
+					return value, nil
+				}
+				return nil, nil
+			}),
+		},
+	}
+}
+
+// mustWrap wraps a built-in function, whose signature is known to be valid.
+func mustWrap(name string, fn any) *NativeFunction {
+	native, err := WrapFunc(name, fn)
+	if err != nil {
+		panic(err)
+	}
+	return native
+}
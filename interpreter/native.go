@@ -0,0 +1,301 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var _ LoxCallable = &NativeFunction{}
+
+// NativeFunction is a Go function exposed to Lox scripts.
+type NativeFunction struct {
+	Name string
+	// arity is the exact number of arguments, or -1 if Fn checks them itself.
+	arity int
+	Fn    func(arguments []any) (any, error)
+}
+
+// NewNativeFunction wraps fn, which receives Lox values as they are.
+func NewNativeFunction(name string, arity int, fn func(arguments []any) (any, error)) *NativeFunction {
+	return &NativeFunction{
+		Name:  name,
+		arity: arity,
+		Fn:    fn,
+	}
+}
+
+// WrapFunc adapts an ordinary Go function to Lox. Its parameters may be
+// numeric types, string, bool, any, or pointers to values the host put into
+// the script; a final ...T parameter makes it variadic. It may return
+// nothing, a value of one of those types, an error, or a value and an
+// error.
+func WrapFunc(name string, fn any) (*NativeFunction, error) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("native %s: %T is not a function", name, fn)
+	}
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	switch {
+	case ft.NumOut() == 0:
+	case ft.NumOut() == 1:
+	case ft.NumOut() == 2 && ft.Out(1) == errorType:
+	default:
+		return nil, fmt.Errorf("native %s: must return at most a value and an error", name)
+	}
+
+	for idx := 0; idx < ft.NumIn(); idx++ {
+		t := ft.In(idx)
+		if ft.IsVariadic() && idx == ft.NumIn()-1 {
+			t = t.Elem()
+		}
+		if !supportedType(t) {
+			return nil, fmt.Errorf("native %s: parameter %d has unsupported type %s", name, idx+1, t)
+		}
+	}
+	if ft.NumOut() > 0 && ft.Out(0) != errorType && !supportedType(ft.Out(0)) {
+		return nil, fmt.Errorf("native %s: result has unsupported type %s", name, ft.Out(0))
+	}
+
+	arity := ft.NumIn()
+	if ft.IsVariadic() {
+		arity = -1
+	}
+	native := NewNativeFunction(name, arity, func(arguments []any) (any, error) {
+		fixed := ft.NumIn()
+		if ft.IsVariadic() {
+			fixed--
+			if len(arguments) < fixed {
+				return nil, fmt.Errorf("Expected at least %d arguments but got %d.", fixed, len(arguments))
+			}
+		}
+
+		in := make([]reflect.Value, 0, len(arguments))
+		for idx, argument := range arguments {
+			var t reflect.Type
+			if idx < fixed {
+				t = ft.In(idx)
+			} else {
+				t = ft.In(fixed).Elem()
+			}
+			v, err := fromLox(argument, t)
+			if err != nil {
+				return nil, fmt.Errorf("Argument %d to '%s' %s.", idx+1, name, err)
+			}
+			in = append(in, v)
+		}
+
+		out := fv.Call(in)
+		if len(out) == 0 {
+			return nil, nil
+		}
+		if last := out[len(out)-1]; ft.Out(len(out)-1) == errorType {
+			if !last.IsNil() {
+				return nil, last.Interface().(error)
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return out[0].Interface(), nil
+	})
+	return native, nil
+}
+
+// Invoke calls the Go function, turning a Go panic into an error and its
+// result into a Lox value.
+func (n *NativeFunction) Invoke(arguments []any) (result any, err error) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			result = nil
+			err = fmt.Errorf("Native function '%s' failed: %v", n.Name, terr)
+		}
+	}()
+	result, err = n.Fn(arguments)
+	if err != nil {
+		return nil, err
+	}
+	value, err := ToLox(result)
+	if err != nil {
+		return nil, fmt.Errorf("Native function '%s' returned %v.", n.Name, err)
+	}
+	return value, nil
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+// Call satisfies LoxCallable. The interpreter calls natives through Invoke so
+// that errors are reported at the call site.
+func (n *NativeFunction) Call(interpreter *Interpreter, arguments []any) any {
+	result, err := n.Invoke(arguments)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// ToLox converts a Go value from the host into a Lox value. Go numbers
+// become float64, and named string and bool types their underlying type;
+// nil pointers become nil. Other pointers, the host's own objects, are
+// passed through. Values of any other kind, such as slices, maps and
+// functions, are rejected: scripts could not use them, and comparing them
+// would panic.
+func ToLox(value any) (any, error) {
+	switch value.(type) {
+	case nil, bool, float64, string:
+		return value, nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported Go type %T", value)
+}
+
+// supportedType reports whether values of type t can pass between Go and
+// Lox.
+func supportedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Pointer, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var errNotInteger = errors.New("must be an integer")
+
+// fromLox converts a Lox argument to the Go parameter type t.
+func fromLox(value any, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface && value == nil {
+		return reflect.Zero(t), nil
+	}
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(float64)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("must be a number, not %s", TypeName(value))
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.(float64)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("must be a number, not %s", TypeName(value))
+		}
+		if n != math.Trunc(n) {
+			return reflect.Value{}, errNotInteger
+		}
+		return integer(n, t)
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("must be a string, not %s", TypeName(value))
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("must be a boolean, not %s", TypeName(value))
+		}
+		return reflect.ValueOf(b).Convert(t), nil
+	}
+	isNamed := t.Implements(reflect.TypeOf((*TypeNamer)(nil)).Elem()) && t.Kind() == reflect.Pointer
+	if value == nil {
+		// a nil list or instance would only make the Go function panic
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			if !isNamed {
+				return reflect.Zero(t), nil
+			}
+		}
+	} else if v := reflect.ValueOf(value); v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	if isNamed {
+		name := reflect.Zero(t).Interface().(TypeNamer).TypeName()
+		return reflect.Value{}, fmt.Errorf("must be a %s, not %s", name, TypeName(value))
+	}
+	return reflect.Value{}, fmt.Errorf("must be %s, not %s", t, TypeName(value))
+}
+
+// integer converts the whole number n to the integer type t, failing if t
+// cannot hold it rather than wrapping around.
+func integer(n float64, t reflect.Type) (reflect.Value, error) {
+	bits := t.Bits()
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// 2^bits is exact as a float64, unlike the largest value itself
+		if n < 0 || n >= math.Ldexp(1, bits) {
+			return reflect.Value{}, fmt.Errorf("must be between 0 and %d", uint64(math.MaxUint64)>>(64-bits))
+		}
+		return reflect.ValueOf(uint64(n)).Convert(t), nil
+	}
+	if n < -math.Ldexp(1, bits-1) || n >= math.Ldexp(1, bits-1) {
+		return reflect.Value{}, fmt.Errorf("must be between %d and %d", int64(-1)<<(bits-1), int64(math.MaxInt64)>>(64-bits))
+	}
+	return reflect.ValueOf(int64(n)).Convert(t), nil
+}
+
+// TypeNamer is implemented by runtime objects to name their Lox type.
+type TypeNamer interface {
+	TypeName() string
+}
+
+// TypeName returns the Lox name of the type of value.
+func TypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case TypeNamer:
+		return v.TypeName()
+	}
+	return "object"
+}
+
+func (n *NativeFunction) TypeName() string {
+	return "function"
+}
+
+func (f *LoxFunction) TypeName() string {
+	return "function"
+}
+
+func (c *LoxClass) TypeName() string {
+	return "class"
+}
+
+func (l *LoxInstance) TypeName() string {
+	return "instance"
+}
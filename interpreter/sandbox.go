@@ -0,0 +1,100 @@
+package interpreter
+
+import (
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxCallDepth bounds recursion when Limits leave MaxCallDepth unset.
+const DefaultMaxCallDepth = 1000
+
+var (
+	ErrStringLength   = errors.New("string length exceeded")
+	ErrCollectionSize = errors.New("collection size exceeded")
+	ErrEnvDepth       = errors.New("environment depth exceeded")
+)
+
+// Sandbox configures an interpreter for untrusted scripts. Zero caps are
+// unlimited.
+type Sandbox struct {
+	// Modules names the built-in modules to install. None are by default,
+	// so scripts get no clock or environment access unless granted.
+	Modules []string
+	Limits  Limits
+	// MaxStringLength caps the length in bytes of strings a script builds.
+	MaxStringLength int
+	// MaxCollectionSize caps the number of elements of a list, entries of a
+	// map and fields of an instance.
+	MaxCollectionSize int
+	// MaxEnvDepth caps how deeply scopes may nest.
+	MaxEnvDepth int
+	// IO is what the io module works on if Modules includes it. The zero
+	// value reads no input, writes to the interpreter's stdout and has no
+	// filesystem.
+	IO IO
+}
+
+// NewSandbox returns an interpreter restricted by sandbox. As with any
+// interpreter, Interpret reports every failure as an error and never lets a
+// Go panic escape.
+func NewSandbox(sandbox Sandbox) (*Interpreter, error) {
+	i := newInterpreter()
+	for _, name := range sandbox.Modules {
+		module, ok := LookupModule(name)
+		if !ok {
+			return nil, fmt.Errorf("sandbox: unknown module %q", name)
+		}
+		if name == "io" {
+			i.InstallIO(sandbox.IO)
+			continue
+		}
+		i.InstallModule(module)
+	}
+	i.SetLimits(sandbox.Limits)
+	i.caps = sandbox
+	return i, nil
+}
+
+func (i *Interpreter) checkStringLength(token scanner.Token, s string) {
+	if i.caps.MaxStringLength > 0 && len(s) > i.caps.MaxStringLength {
+		panic(NewLimitError(token, ErrStringLength))
+	}
+}
+
+func (i *Interpreter) checkCollectionSize(token scanner.Token, size int) {
+	if i.caps.MaxCollectionSize > 0 && size > i.caps.MaxCollectionSize {
+		panic(NewLimitError(token, ErrCollectionSize))
+	}
+}
+
+// checkNativeResult applies the caps to what a native function built or
+// grew: its result and the lists it was passed.
+func (i *Interpreter) checkNativeResult(token scanner.Token, arguments []any, result any) {
+	if i.caps.MaxStringLength > 0 {
+		if s, ok := result.(string); ok {
+			i.checkStringLength(token, s)
+		}
+	}
+	if i.caps.MaxCollectionSize > 0 {
+		for _, value := range append(arguments, result) {
+			switch v := value.(type) {
+			case *LoxList:
+				i.checkCollectionSize(token, len(v.Elements))
+			case *LoxMap:
+				i.checkCollectionSize(token, v.Len())
+			}
+		}
+	}
+}
+
+func (i *Interpreter) checkEnvDepth(statements []parser.Stmt, env *Environment) {
+	if i.caps.MaxEnvDepth > 0 && env.depth > i.caps.MaxEnvDepth {
+		var token scanner.Token
+		if len(statements) > 0 {
+			token = stmtToken(statements[0])
+		}
+		panic(NewLimitError(i.orEnclosing(token), ErrEnvDepth))
+	}
+}
@@ -0,0 +1,148 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stringify formats value as text for str, join and printed collections,
+// spelling nil as it is written in scripts.
+func Stringify(value any) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprint(value)
+}
+
+// coreModule holds conversions and string functions. Strings are indexed by
+// character, not by byte.
+func coreModule() *Module {
+	return &Module{
+		Name: "core",
+		Functions: []*NativeFunction{
+			NewNativeFunction("len", 1, func(arguments []any) (any, error) {
+				switch v := arguments[0].(type) {
+				case string:
+					return float64(utf8.RuneCountInString(v)), nil
+				case *LoxList:
+					return float64(len(v.Elements)), nil
+				case *LoxMap:
+					return float64(v.Len()), nil
+				}
+				return nil, fmt.Errorf("Argument 1 to 'len' must be a string, list or map, not %s.", TypeName(arguments[0]))
+			}),
+			NewNativeFunction("str", 1, func(arguments []any) (any, error) {
+				return Stringify(arguments[0]), nil
+			}),
+			NewNativeFunction("num", 1, func(arguments []any) (any, error) {
+				switch v := arguments[0].(type) {
+				case float64:
+					return v, nil
+				case string:
+					n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+					if err != nil {
+						return nil, nil
+					}
+					return n, nil
+				}
+				return nil, fmt.Errorf("Argument 1 to 'num' must be a string or number, not %s.", TypeName(arguments[0]))
+			}),
+			mustWrap("substr", func(s string, start int, end ...int) (string, error) {
+				runes := []rune(s)
+				stop := len(runes)
+				if len(end) > 1 {
+					return "", fmt.Errorf("Expected at most 3 arguments but got %d.", len(end)+2)
+				}
+				if len(end) == 1 {
+					stop = end[0]
+				}
+				if start < 0 || stop > len(runes) || start > stop {
+					return "", fmt.Errorf("Substring [%d, %d) out of range for length %d.", start, stop, len(runes))
+				}
+				return string(runes[start:stop]), nil
+			}),
+			mustWrap("indexOf", func(s, sub string) int {
+				idx := strings.Index(s, sub)
+				if idx < 0 {
+					return -1
+				}
+				return utf8.RuneCountInString(s[:idx])
+			}),
+			mustWrap("upper", strings.ToUpper),
+			mustWrap("lower", strings.ToLower),
+			mustWrap("split", func(s, sep string) *LoxList {
+				parts := strings.Split(s, sep)
+				elements := make([]any, len(parts))
+				for idx, part := range parts {
+					elements[idx] = part
+				}
+				return NewLoxList(elements)
+			}),
+			mustWrap("join", func(list *LoxList, sep string) string {
+				parts := make([]string, len(list.Elements))
+				for idx, element := range list.Elements {
+					parts[idx] = Stringify(element)
+				}
+				return strings.Join(parts, sep)
+			}),
+			mustWrap("push", (*LoxList).push),
+			mustWrap("pop", (*LoxList).pop),
+			mustWrap("insert", (*LoxList).insert),
+			mustWrap("remove", (*LoxList).remove),
+			mustWrap("slice", (*LoxList).slice),
+			mustWrap("sort", (*LoxList).sort),
+			mustWrap("keys", (*LoxMap).keyList),
+			mustWrap("values", (*LoxMap).valueList),
+			mustWrap("entries", (*LoxMap).entryList),
+			mustWrap("has", (*LoxMap).has),
+			mustWrap("delete", (*LoxMap).delete),
+			NewNativeFunction("type", 1, func(arguments []any) (any, error) {
+				return TypeName(arguments[0]), nil
+			}),
+		},
+	}
+}
+
+func mathModule() *Module {
+	return &Module{
+		Name: "math",
+		Functions: []*NativeFunction{
+			mustWrap("floor", math.Floor),
+			mustWrap("ceil", math.Ceil),
+			mustWrap("sqrt", math.Sqrt),
+			mustWrap("pow", math.Pow),
+			mustWrap("abs", math.Abs),
+			mustWrap("min", func(first float64, rest ...float64) float64 {
+				for _, n := range rest {
+					first = math.Min(first, n)
+				}
+				return first
+			}),
+			mustWrap("max", func(first float64, rest ...float64) float64 {
+				for _, n := range rest {
+					first = math.Max(first, n)
+				}
+				return first
+			}),
+		},
+	}
+}
+
+// RandomModule returns the random module drawing from source, which a host
+// can seed to make scripts repeatable. Scripts can reseed it with seed(n).
+func RandomModule(source rand.Source) *Module {
+	r := rand.New(source)
+	return &Module{
+		Name: "random",
+		Functions: []*NativeFunction{
+			mustWrap("random", r.Float64),
+			mustWrap("seed", func(seed int64) {
+				r.Seed(seed)
+			}),
+		},
+	}
+}
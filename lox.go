@@ -1,103 +1,108 @@
 package main
 
 import (
-	"bufio"
-	"craftinginterpreters/lox/interpreter"
-	"craftinginterpreters/lox/parser"
-	"craftinginterpreters/lox/scanner"
+	"craftinginterpreters/lox/lox"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 )
 
+// Exit codes follow the BSD sysexits.h conventions.
+const (
+	exitUsage    = 64 // EX_USAGE: bad command line
+	exitDataErr  = 65 // EX_DATAERR: the script has scan, parse or resolve errors
+	exitSoftware = 70 // EX_SOFTWARE: the script failed at runtime
+	exitIOErr    = 74 // EX_IOERR: the script could not be read
+)
+
 func main() {
-	promptFlag := flag.Bool("p", false, "process model")
-	flag.Parse()
+	// the default ExitOnError would exit with 2 rather than EX_USAGE
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: lox [flags] [script]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Starts an interactive prompt when no script is given.\n")
+		flag.PrintDefaults()
+	}
+	promptFlag := flag.Bool("p", false, "start the interactive prompt")
+	vmFlag := flag.Bool("vm", false, "run on the bytecode virtual machine")
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(exitUsage)
+	}
 
-	if len(flag.Args()) == 0 {
-		panic("need input lox file")
+	if len(flag.Args()) > 1 || (*promptFlag && len(flag.Args()) != 0) {
+		flag.Usage()
+		os.Exit(exitUsage)
 	}
-	lox := newLox()
+	l := newLox(*vmFlag)
 
-	if *promptFlag {
-		lox.RunPrompt()
+	if len(flag.Args()) == 0 {
+		l.RunPrompt()
+		return
 	}
-	lox.RunFile(flag.Arg(0))
+	l.RunFile(flag.Arg(0))
 }
 
 type Lox struct {
-	interpreter     *interpreter.Interpreter
+	runtime         *lox.Runtime
+	useVM           bool
 	hadError        bool
 	hadRuntimeError bool
 }
 
-func newLox() *Lox {
-	return &Lox{
-		interpreter: interpreter.NewInterpreter(),
+func newLox(useVM bool) *Lox {
+	options := []lox.Option{}
+	if useVM {
+		options = append(options, lox.WithVM())
 	}
-}
-
-func (l *Lox) RunPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Printf(">> ")
-		if !scanner.Scan() {
-			break
-		}
-		l.run(scanner.Text())
-		l.hadError = false
-	}
-	if err := scanner.Err(); err != nil {
-		fmt.Println(err)
+	return &Lox{
+		runtime: lox.New(options...),
+		useVM:   useVM,
 	}
 }
 
+// RunFile runs the script in name and exits the process if it failed.
 func (l *Lox) RunFile(name string) {
-	f, err := os.Open(name)
-	if err != nil {
-		panic(err)
+	_, err := l.runtime.EvalFile(name)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitIOErr)
 	}
-	loxContext, err := io.ReadAll(f)
-	if err != nil {
-		panic(err)
-	}
-	l.run(string(loxContext))
+	l.check(err)
 	if l.hadError {
-		panic("lox has error")
+		os.Exit(exitDataErr)
+	}
+	if l.hadRuntimeError {
+		os.Exit(exitSoftware)
 	}
 }
 
-func (l *Lox) run(loxContext string) {
-	scanner := scanner.New()
-	tokens := scanner.ScanAll(loxContext)
-
-	parsers := parser.NewParser(tokens)
-	statements, err := parsers.Parse()
-	if err != nil {
-		l.Error(err)
-		return
+// run executes loxContext. With echo set, as in the REPL, the value of a
+// trailing expression is printed.
+func (l *Lox) run(loxContext string, echo bool) {
+	value, err := l.runtime.Eval(loxContext)
+	l.check(err)
+	if err == nil && echo && value != nil {
+		fmt.Println(lox.Format(value))
 	}
+}
 
-	err = l.interpreter.Interpret(statements)
-	if err != nil {
-		l.runtimeError(err)
+// check reports err and records whether it was found before or while the
+// script ran.
+func (l *Lox) check(err error) {
+	if err == nil {
 		return
 	}
-
-}
-
-func (l *Lox) Error(err error) {
-	l.hadError = true
-	fmt.Println(err)
-}
-
-func (l *Lox) runtimeError(err error) {
-	l.hadRuntimeError = true
-	fmt.Println(err)
-}
-
-func (l *Lox) report(line int, where, message string) {
-	fmt.Printf("[line %d ] Error %s: %s", line, where, message)
-	l.hadError = true
+	var compileErr *lox.CompileError
+	if errors.As(err, &compileErr) {
+		l.hadError = true
+	} else {
+		l.hadRuntimeError = true
+	}
+	l.runtime.ReportError(err)
 }
@@ -0,0 +1,41 @@
+package lox
+
+import (
+	"craftinginterpreters/lox/diagnostics"
+	"errors"
+	"strings"
+)
+
+// CompileError holds the errors that stopped a script before it ran.
+type CompileError struct {
+	Errors []error
+}
+
+func (e *CompileError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e *CompileError) Diagnostics() []diagnostics.Diagnostic {
+	res := make([]diagnostics.Diagnostic, 0)
+	for _, err := range e.Errors {
+		var d diagnostics.Diagnoser
+		if errors.As(err, &d) {
+			res = append(res, d.Diagnostics()...)
+		}
+	}
+	return res
+}
+
+// As lets errors.As find an error of a specific phase inside e.
+func (e *CompileError) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
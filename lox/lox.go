@@ -0,0 +1,298 @@
+// Package lox embeds the Lox language in Go programs.
+//
+//	rt := lox.New(lox.WithStdout(&buf))
+//	rt.SetGlobal("limit", 10)
+//	value, err := rt.Eval("limit * 2")
+package lox
+
+import (
+	"craftinginterpreters/lox/diagnostics"
+	"craftinginterpreters/lox/interpreter"
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/resolver"
+	"craftinginterpreters/lox/scanner"
+	"craftinginterpreters/lox/vm"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Value is a Lox value: nil, bool, float64, string, or a function, class or
+// instance created by a script.
+type Value = any
+
+type (
+	ScanError    = scanner.ScanError
+	ParseError   = parser.ParseError
+	ResolveError = resolver.ResolveError
+	RuntimeError = interpreter.RuntimeError
+	// StackFrame is one frame of RuntimeError.Stack, innermost first.
+	StackFrame = interpreter.StackFrame
+)
+
+// Runtime holds the global state of a Lox program across Eval calls.
+type Runtime struct {
+	interpreter *interpreter.Interpreter
+	vm          *vm.VM
+	stdin       io.Reader
+	stdout      io.Writer
+	stderr      io.Writer
+	fsys        fs.FS
+	// sourceName and source are the last evaluated script, used by
+	// ReportError.
+	sourceName string
+	source     string
+	// sources holds every script read from a file by name, so that errors
+	// in functions declared by an earlier script show that script's text.
+	sources map[string]string
+}
+
+type Option func(*Runtime)
+
+// WithStdout sets where print statements write. The default is os.Stdout.
+func WithStdout(w io.Writer) Option {
+	return func(r *Runtime) {
+		r.stdout = w
+	}
+}
+
+// WithStdin sets where the readLine function reads. The default is
+// os.Stdin.
+func WithStdin(r io.Reader) Option {
+	return func(rt *Runtime) {
+		rt.stdin = r
+	}
+}
+
+// WithFS sets the filesystem scripts read and, if it is an
+// interpreter.FileSystem, write. The default is the host's filesystem; nil
+// leaves scripts without one.
+func WithFS(fsys fs.FS) Option {
+	return func(r *Runtime) {
+		r.fsys = fsys
+	}
+}
+
+// WithStderr sets where ReportError writes. The default is os.Stderr.
+func WithStderr(w io.Writer) Option {
+	return func(r *Runtime) {
+		r.stderr = w
+	}
+}
+
+// WithVM runs scripts on the bytecode virtual machine instead of the
+// tree-walking interpreter.
+func WithVM() Option {
+	return func(r *Runtime) {
+		r.vm = vm.New()
+	}
+}
+
+func New(options ...Option) *Runtime {
+	r := &Runtime{
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		fsys:    interpreter.OSFileSystem{},
+		sources: map[string]string{},
+	}
+	for _, option := range options {
+		option(r)
+	}
+	config := interpreter.IO{Stdin: r.stdin, FS: r.fsys}
+	if r.vm != nil {
+		r.vm.SetStdout(r.stdout)
+		r.vm.InstallIO(config)
+	} else {
+		r.interpreter = interpreter.NewInterpreter()
+		r.interpreter.SetStdout(r.stdout)
+		r.interpreter.InstallIO(config)
+	}
+	return r
+}
+
+// Eval runs src. If its last statement is an expression statement the value
+// is returned; that statement may leave out its ';'.
+//
+// Errors found before the script runs are returned as a *CompileError;
+// errors raised while running it are *RuntimeError, whose Stack holds the
+// Lox calls in progress.
+func (r *Runtime) Eval(src string) (Value, error) {
+	return r.eval("", src, true)
+}
+
+// EvalFile reads and runs the script in name, like Eval, except that every
+// statement must end in its ';'.
+func (r *Runtime) EvalFile(name string) (Value, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.eval(name, string(src), false)
+}
+
+// eval runs src as one script, so that limits apply to it as a whole. With
+// trailing set, its last expression statement may leave out its ';'.
+func (r *Runtime) eval(name, src string, trailing bool) (Value, error) {
+	r.sourceName = name
+	r.source = src
+	if name != "" {
+		r.sources[name] = src
+	}
+	if r.vm != nil {
+		r.vm.SetSourceName(name)
+	} else {
+		r.interpreter.SetSourceName(name)
+	}
+
+	statements, err := r.compile(src, trailing)
+	if err != nil {
+		return nil, err
+	}
+	if r.vm != nil {
+		value, err := r.vm.Eval(statements)
+		return value.Any(), compileErrorOf(err)
+	}
+	return r.interpreter.Eval(statements)
+}
+
+// compile scans, parses and resolves src.
+func (r *Runtime) compile(src string, trailing bool) ([]parser.Stmt, error) {
+	errs := CompileError{}
+
+	s := scanner.New()
+	tokens := s.ScanAll(src)
+	if err := s.Err(); err != nil {
+		errs.Errors = append(errs.Errors, err)
+	}
+
+	p := parser.NewParser(tokens)
+	p.AllowTrailingExpression = trailing
+	statements, err := p.Parse()
+	if err != nil {
+		errs.Errors = append(errs.Errors, err)
+	}
+	if len(errs.Errors) != 0 {
+		return nil, &errs
+	}
+
+	if err := resolver.NewResolver(r.interpreter).Resolve(statements); err != nil {
+		errs.Errors = append(errs.Errors, err)
+		return nil, &errs
+	}
+	return statements, nil
+}
+
+// compileErrorOf wraps the bytecode compiler's errors so that they are
+// reported like the other static errors.
+func compileErrorOf(err error) error {
+	if perr, ok := err.(*parser.ParseError); ok {
+		return &CompileError{Errors: []error{perr}}
+	}
+	return err
+}
+
+// SetGlobal defines a global variable visible to scripts. Go integer and
+// float types are converted to Lox numbers; values scripts cannot use, such
+// as slices and maps, are rejected as described by interpreter.ToLox.
+func (r *Runtime) SetGlobal(name string, value Value) error {
+	value, err := interpreter.ToLox(value)
+	if err != nil {
+		return fmt.Errorf("global %s: %w", name, err)
+	}
+	if r.vm != nil {
+		r.vm.DefineGlobal(name, vm.FromAny(value))
+		return nil
+	}
+	r.interpreter.DefineGlobal(name, value)
+	return nil
+}
+
+// RegisterFunc makes the Go function fn callable from scripts as name.
+// Arguments and results are converted as described by interpreter.WrapFunc,
+// and a returned error becomes a runtime error at the call site.
+func (r *Runtime) RegisterFunc(name string, fn any) error {
+	if r.vm != nil {
+		return r.vm.RegisterFunc(name, fn)
+	}
+	return r.interpreter.RegisterFunc(name, fn)
+}
+
+// DefineNative makes fn callable from scripts as name. It receives the Lox
+// arguments unconverted; an arity of -1 accepts any number of them.
+func (r *Runtime) DefineNative(name string, arity int, fn func(arguments []Value) (Value, error)) {
+	if r.vm != nil {
+		r.vm.DefineNative(name, arity, fn)
+		return
+	}
+	r.interpreter.DefineNative(name, arity, fn)
+}
+
+// GetGlobal returns the value of a global variable.
+func (r *Runtime) GetGlobal(name string) (Value, bool) {
+	if r.vm != nil {
+		value, ok := r.vm.Global(name)
+		return value.Any(), ok
+	}
+	return r.interpreter.Global(name)
+}
+
+// Globals returns a copy of every global variable.
+func (r *Runtime) Globals() map[string]Value {
+	if r.vm == nil {
+		return r.interpreter.Globals()
+	}
+	res := map[string]Value{}
+	for name, value := range r.vm.Globals() {
+		res[name] = value.Any()
+	}
+	return res
+}
+
+// ReportError writes err to the runtime's stderr, with a snippet of the
+// script it came from when that script is known.
+func (r *Runtime) ReportError(err error) {
+	var rerr *RuntimeError
+	if errors.As(err, &rerr) {
+		name, src, ok := r.sourceOf(rerr)
+		if !ok {
+			location := rerr.Token.Start.String()
+			if name != "" {
+				location = name + ":" + location
+			}
+			diagnostics.RenderLocation(r.stderr, location, rerr.Diagnostics()[0])
+			return
+		}
+		diagnostics.RenderAll(r.stderr, name, src, rerr)
+		return
+	}
+	if !diagnostics.RenderAll(r.stderr, r.sourceName, r.source, err) {
+		fmt.Fprintln(r.stderr, err)
+	}
+}
+
+// sourceOf finds the script the position of err is in: the one declaring
+// the function it was raised in. Scripts not read from a file have no name
+// to tell them apart by, so of those only the last one is known, and only
+// for errors raised outside any function.
+func (r *Runtime) sourceOf(err *RuntimeError) (name, src string, ok bool) {
+	if len(err.Stack) == 0 {
+		return r.sourceName, r.source, true
+	}
+	frame := err.Stack[0]
+	if frame.File != "" {
+		src, ok := r.sources[frame.File]
+		return frame.File, src, ok
+	}
+	if r.sourceName == "" && frame.Function == "script" {
+		return "", r.source, true
+	}
+	return "", "", false
+}
+
+// Format returns value as print would show it.
+func Format(value Value) string {
+	return fmt.Sprint(value)
+}
@@ -18,17 +18,64 @@ func (a AstPrinter) VisitBinaryExpr(b *Binary) any {
 	return a.parenthesize(b.Operator.Lexeme, b.Left, b.Right)
 }
 
+func (a AstPrinter) VisitCallExpr(c *Call) any {
+	return a.parenthesize("call", append([]Expr{c.Callee}, c.Arguments...)...)
+}
+
+func (a AstPrinter) VisitGetExpr(g *Get) any {
+	return a.parenthesize("."+g.Name.Lexeme, g.Object)
+}
+
 func (a AstPrinter) VisitGroupingExpr(g *Grouping) any {
 	return a.parenthesize("group", g.Expression)
 }
 
+func (a AstPrinter) VisitIndexExpr(i *Index) any {
+	return a.parenthesize("[]", i.Object, i.Index)
+}
+
+func (a AstPrinter) VisitListExpr(l *List) any {
+	return a.parenthesize("list", l.Elements...)
+}
+
 func (a AstPrinter) VisitLiteralExpr(l *Literal) any {
 	if l.Value == nil {
 		return "nil"
 	}
+	if s, ok := l.Value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
 	return fmt.Sprint(l.Value)
 }
 
+func (a AstPrinter) VisitLogicalExpr(l *Logical) any {
+	return a.parenthesize(l.Operator.Lexeme, l.Left, l.Right)
+}
+
+func (a AstPrinter) VisitMapExpr(m *Map) any {
+	entries := make([]Expr, 0, 2*len(m.Keys))
+	for idx := range m.Keys {
+		entries = append(entries, m.Keys[idx], m.Values[idx])
+	}
+	return a.parenthesize("map", entries...)
+}
+
+func (a AstPrinter) VisitSetExpr(s *Set) any {
+	return a.parenthesize("="+s.Name.Lexeme, s.Object, s.Value)
+}
+
+func (a AstPrinter) VisitSetIndexExpr(s *SetIndex) any {
+	return a.parenthesize("[]=", s.Object, s.Index, s.Value)
+}
+
+func (a AstPrinter) VisitSuperExpr(s *Super) any {
+	return "super." + s.Method.Lexeme
+}
+
+func (a AstPrinter) VisitThisExpr(t *This) any {
+	return "this"
+}
+
 func (a AstPrinter) VisitUnaryExpr(u *Unary) any {
 	return a.parenthesize(u.Operator.Lexeme, u.Right)
 }
@@ -48,9 +95,9 @@ func (a AstPrinter) parenthesize(name string, exprs ...Expr) string {
 }
 
 func (a AstPrinter) VisitVariableExpr(v *Variable) any {
-	return nil
+	return v.Name.Lexeme
 }
 
 func (a AstPrinter) VisitAssignExpr(v *Assign) any {
-	return nil
+	return a.parenthesize("= "+v.Name.Lexeme, v.Value)
 }
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"craftinginterpreters/lox/diagnostics"
+	"craftinginterpreters/lox/scanner"
+	"fmt"
+	"strings"
+)
+
+// ParseError is a single syntax error at Token.
+type ParseError struct {
+	Token   scanner.Token
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	where := "at '" + e.Token.Lexeme + "'"
+	if e.Token.Type == scanner.EOF {
+		where = "at end"
+	}
+	return fmt.Sprintf("[line %s ] Error %s: %s", e.Token.Start, where, e.Message)
+}
+
+func (e *ParseError) Diagnostics() []diagnostics.Diagnostic {
+	d := diagnostics.Diagnostic{
+		Code:    diagnostics.CodeSyntax,
+		Message: e.Message,
+		Start:   e.Token.Start.Offset,
+		End:     e.Token.End.Offset,
+	}
+	if e.Token.Type == scanner.EOF {
+		d.Notes = append(d.Notes, "reached the end of the input")
+	}
+	return []diagnostics.Diagnostic{d}
+}
+
+// ParseErrors collects every syntax error found in one Parse call.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e ParseErrors) Diagnostics() []diagnostics.Diagnostic {
+	res := make([]diagnostics.Diagnostic, 0, len(e))
+	for _, err := range e {
+		res = append(res, err.Diagnostics()...)
+	}
+	return res
+}
+
+// As lets errors.As find the first *ParseError in the collection.
+func (e ParseErrors) As(target any) bool {
+	if t, ok := target.(**ParseError); ok && len(e) != 0 {
+		*t = e[0]
+		return true
+	}
+	return false
+}
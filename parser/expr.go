@@ -8,8 +8,18 @@ import (
 type ExprVisitor interface {
 	VisitAssignExpr(*Assign) any
 	VisitBinaryExpr(*Binary) any
+	VisitCallExpr(*Call) any
+	VisitGetExpr(*Get) any
 	VisitGroupingExpr(*Grouping) any
+	VisitIndexExpr(*Index) any
+	VisitListExpr(*List) any
 	VisitLiteralExpr(*Literal) any
+	VisitLogicalExpr(*Logical) any
+	VisitMapExpr(*Map) any
+	VisitSetExpr(*Set) any
+	VisitSetIndexExpr(*SetIndex) any
+	VisitSuperExpr(*Super) any
+	VisitThisExpr(*This) any
 	VisitUnaryExpr(*Unary) any
 	VisitVariableExpr(*Variable) any
 }
@@ -37,6 +47,25 @@ func (i *Binary) Accept(v ExprVisitor) any {
 	return v.VisitBinaryExpr(i)
 }
 
+type Call struct {
+	Callee    Expr
+	Paren     scanner.Token
+	Arguments []Expr
+}
+
+func (i *Call) Accept(v ExprVisitor) any {
+	return v.VisitCallExpr(i)
+}
+
+type Get struct {
+	Object Expr
+	Name   scanner.Token
+}
+
+func (i *Get) Accept(v ExprVisitor) any {
+	return v.VisitGetExpr(i)
+}
+
 type Grouping struct {
 	Expression Expr
 }
@@ -45,7 +74,27 @@ func (i *Grouping) Accept(v ExprVisitor) any {
 	return v.VisitGroupingExpr(i)
 }
 
+type Index struct {
+	Object  Expr
+	Bracket scanner.Token
+	Index   Expr
+}
+
+func (i *Index) Accept(v ExprVisitor) any {
+	return v.VisitIndexExpr(i)
+}
+
+type List struct {
+	Bracket  scanner.Token
+	Elements []Expr
+}
+
+func (i *List) Accept(v ExprVisitor) any {
+	return v.VisitListExpr(i)
+}
+
 type Literal struct {
+	Token scanner.Token
 	Value any
 }
 
@@ -53,6 +102,64 @@ func (i *Literal) Accept(v ExprVisitor) any {
 	return v.VisitLiteralExpr(i)
 }
 
+type Logical struct {
+	Left     Expr
+	Operator scanner.Token
+	Right    Expr
+}
+
+func (i *Logical) Accept(v ExprVisitor) any {
+	return v.VisitLogicalExpr(i)
+}
+
+type Map struct {
+	Brace  scanner.Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func (i *Map) Accept(v ExprVisitor) any {
+	return v.VisitMapExpr(i)
+}
+
+type Set struct {
+	Object Expr
+	Name   scanner.Token
+	Value  Expr
+}
+
+func (i *Set) Accept(v ExprVisitor) any {
+	return v.VisitSetExpr(i)
+}
+
+type SetIndex struct {
+	Object  Expr
+	Bracket scanner.Token
+	Index   Expr
+	Value   Expr
+}
+
+func (i *SetIndex) Accept(v ExprVisitor) any {
+	return v.VisitSetIndexExpr(i)
+}
+
+type Super struct {
+	Keyword scanner.Token
+	Method  scanner.Token
+}
+
+func (i *Super) Accept(v ExprVisitor) any {
+	return v.VisitSuperExpr(i)
+}
+
+type This struct {
+	Keyword scanner.Token
+}
+
+func (i *This) Accept(v ExprVisitor) any {
+	return v.VisitThisExpr(i)
+}
+
 type Unary struct {
 	Operator scanner.Token
 	Right    Expr
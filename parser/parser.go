@@ -2,7 +2,6 @@ package parser
 
 import (
 	"craftinginterpreters/lox/scanner"
-	"errors"
 	"fmt"
 )
 
@@ -10,6 +9,10 @@ type Parser struct {
 	Tokens   []scanner.Token
 	current  int
 	HadError bool
+	// AllowTrailingExpression lets the last expression statement omit its
+	// ';', so "1 + 2" can be evaluated at a prompt.
+	AllowTrailingExpression bool
+	errors                  ParseErrors
 }
 
 func NewParser(t []scanner.Token) *Parser {
@@ -18,27 +21,114 @@ func NewParser(t []scanner.Token) *Parser {
 	}
 }
 
+// Parse parses the whole token stream. If there are syntax errors it returns
+// all of them as ParseErrors.
 func (p *Parser) Parse() (res []Stmt, err error) {
 	defer func() {
 		if terr := recover(); terr != nil {
+			// Declaration handles every ParseError, so anything else is a bug
 			res = nil
-			err = terr.(error)
+			err = fmt.Errorf("internal parser error: %v", terr)
 		}
 	}()
 	statements := make([]Stmt, 0)
 	for !p.isAtEnd() {
 		statements = append(statements, p.Declaration())
 	}
+	if len(p.errors) != 0 {
+		return nil, p.errors
+	}
 	return statements, nil
 }
 
-func (p *Parser) Declaration() Stmt {
+// ParseExpression parses the whole token stream as a single expression with
+// no trailing ';'. The REPL uses it to evaluate bare expressions.
+func (p *Parser) ParseExpression() (expr Expr, err error) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			expr = nil
+			if perr, ok := terr.(*ParseError); ok {
+				err = ParseErrors{perr}
+				return
+			}
+			err = fmt.Errorf("internal parser error: %v", terr)
+		}
+	}()
+	expr = p.Expression()
+	if !p.isAtEnd() {
+		p.Error(p.peek(), "Expect end of expression.")
+	}
+	if len(p.errors) != 0 {
+		return nil, p.errors
+	}
+	return expr, nil
+}
+
+// Declaration parses one declaration. On a syntax error it skips to the next
+// statement boundary and returns nil so parsing can continue.
+func (p *Parser) Declaration() (stmt Stmt) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			if _, ok := terr.(*ParseError); !ok {
+				panic(terr)
+			}
+			p.synchronize()
+			stmt = nil
+		}
+	}()
+	if p.match(scanner.CLASS) {
+		return p.ClassDeclaration()
+	}
+	if p.match(scanner.FUN) {
+		return p.Function("function")
+	}
 	if p.match(scanner.VAR) {
 		return p.VarDeclaration()
 	}
 	return p.Statement()
 }
 
+func (p *Parser) ClassDeclaration() Stmt {
+	name := p.comsume(scanner.IDENTIFIER, "Expect class name.")
+
+	var superclass *Variable
+	if p.match(scanner.LESS) {
+		p.comsume(scanner.IDENTIFIER, "Expect superclass name.")
+		superclass = &Variable{p.previous()}
+	}
+
+	p.comsume(scanner.LEFT_BRACE, "Expect '{' before class body.")
+
+	methods := make([]*Function, 0)
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		methods = append(methods, p.Function("method"))
+	}
+	p.comsume(scanner.RIGHT_BRACE, "Expect '}' after class body.")
+	return &Class{name, superclass, methods}
+}
+
+func (p *Parser) Function(kind string) *Function {
+	name := p.comsume(scanner.IDENTIFIER, "Expect "+kind+" name.")
+	p.comsume(scanner.LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	parameters := make([]scanner.Token, 0)
+	if !p.check(scanner.RIGHT_PAREN) {
+		for {
+			if len(parameters) >= 255 {
+				p.record(p.peek(), "Can't have more than 255 parameters.")
+			}
+			parameters = append(parameters, p.comsume(scanner.IDENTIFIER, "Expect parameter name."))
+			if !p.match(scanner.COMMA) {
+				break
+			}
+		}
+	}
+	p.comsume(scanner.RIGHT_PAREN, "Expect ')' after parameters.")
+
+	p.comsume(scanner.LEFT_BRACE, "Expect '{' before "+kind+" body.")
+	body := p.Block()
+	return &Function{name, parameters, body}
+}
+
 func (p *Parser) VarDeclaration() Stmt {
 	name := p.comsume(scanner.IDENTIFIER, "Expect variable name.")
 
@@ -58,7 +148,7 @@ func (p *Parser) Expression() Expr {
 }
 
 func (p *Parser) Assignment() Expr {
-	expr := p.Equality()
+	expr := p.Or()
 	if p.match(scanner.EQUAL) {
 		equals := p.previous()
 		value := p.Assignment()
@@ -69,21 +159,129 @@ func (p *Parser) Assignment() Expr {
 				Value: value,
 			}
 		}
-		panic(fmt.Sprintf("%v Invalid assignment target.", equals))
+		if g, ok := expr.(*Get); ok {
+			return &Set{g.Object, g.Name, value}
+		}
+		if i, ok := expr.(*Index); ok {
+			return &SetIndex{i.Object, i.Bracket, i.Index, value}
+		}
+		p.record(equals, "Invalid assignment target.")
+	}
+	return expr
+}
+
+func (p *Parser) Or() Expr {
+	expr := p.And()
+	for p.match(scanner.OR) {
+		operator := p.previous()
+		right := p.And()
+		expr = &Logical{expr, operator, right}
+	}
+	return expr
+}
+
+func (p *Parser) And() Expr {
+	expr := p.Equality()
+	for p.match(scanner.AND) {
+		operator := p.previous()
+		right := p.Equality()
+		expr = &Logical{expr, operator, right}
 	}
 	return expr
 }
 
 func (p *Parser) Statement() Stmt {
+	if p.match(scanner.FOR) {
+		return p.ForStatement()
+	}
+	if p.match(scanner.IF) {
+		return p.IfStatement()
+	}
 	if p.match(scanner.PRINT) {
 		return p.PrintStatement()
 	}
+	if p.match(scanner.RETURN) {
+		return p.ReturnStatement()
+	}
+	if p.match(scanner.WHILE) {
+		return p.WhileStatement()
+	}
+	if p.match(scanner.THROW) {
+		return p.ThrowStatement()
+	}
+	if p.match(scanner.TRY) {
+		return p.TryStatement()
+	}
 	if p.match(scanner.LEFT_BRACE) {
-		return &Block{p.Block()}
+		brace := p.previous()
+		return &Block{brace, p.Block()}
 	}
 	return p.ExpressionStatement()
 }
 
+func (p *Parser) ForStatement() Stmt {
+	keyword := p.previous()
+	p.comsume(scanner.LEFT_PAREN, "Expect '(' after 'for'.")
+
+	var initializer Stmt
+	if p.match(scanner.SEMICOLON) {
+		initializer = nil
+	} else if p.match(scanner.VAR) {
+		initializer = p.VarDeclaration()
+	} else {
+		initializer = p.ExpressionStatement()
+	}
+
+	var condition Expr
+	if !p.check(scanner.SEMICOLON) {
+		condition = p.Expression()
+	}
+	p.comsume(scanner.SEMICOLON, "Expect ';' after loop condition.")
+
+	var increment Expr
+	if !p.check(scanner.RIGHT_PAREN) {
+		increment = p.Expression()
+	}
+	p.comsume(scanner.RIGHT_PAREN, "Expect ')' after for clauses.")
+
+	body := p.Statement()
+
+	// desugar for loop into while loop
+	if increment != nil {
+		body = &Block{keyword, []Stmt{body, &Expression{increment}}}
+	}
+	if condition == nil {
+		condition = &Literal{keyword, true}
+	}
+	body = &While{keyword, condition, body}
+	if initializer != nil {
+		body = &Block{keyword, []Stmt{initializer, body}}
+	}
+	return body
+}
+
+func (p *Parser) IfStatement() Stmt {
+	p.comsume(scanner.LEFT_PAREN, "Expect '(' after 'if'.")
+	condition := p.Expression()
+	p.comsume(scanner.RIGHT_PAREN, "Expect ')' after if condition.")
+
+	thenBranch := p.Statement()
+	var elseBranch Stmt
+	if p.match(scanner.ELSE) {
+		elseBranch = p.Statement()
+	}
+	return &If{condition, thenBranch, elseBranch}
+}
+
+func (p *Parser) WhileStatement() Stmt {
+	keyword := p.previous()
+	p.comsume(scanner.LEFT_PAREN, "Expect '(' after 'while'.")
+	condition := p.Expression()
+	p.comsume(scanner.RIGHT_PAREN, "Expect ')' after condition.")
+	body := p.Statement()
+	return &While{keyword, condition, body}
+}
+
 func (p *Parser) Block() []Stmt {
 	statements := make([]Stmt, 0)
 	for !p.isAtEnd() && !p.check(scanner.RIGHT_BRACE) {
@@ -94,13 +292,61 @@ func (p *Parser) Block() []Stmt {
 }
 
 func (p *Parser) PrintStatement() Stmt {
+	keyword := p.previous()
 	value := p.Expression()
 	p.comsume(scanner.SEMICOLON, "Expect ';' after value.")
-	return &Print{value}
+	return &Print{keyword, value}
+}
+
+func (p *Parser) ReturnStatement() Stmt {
+	keyword := p.previous()
+	var value Expr
+	if !p.check(scanner.SEMICOLON) {
+		value = p.Expression()
+	}
+	p.comsume(scanner.SEMICOLON, "Expect ';' after return value.")
+	return &Return{keyword, value}
+}
+
+func (p *Parser) ThrowStatement() Stmt {
+	keyword := p.previous()
+	value := p.Expression()
+	p.comsume(scanner.SEMICOLON, "Expect ';' after thrown value.")
+	return &Throw{keyword, value}
+}
+
+// TryStatement parses a try block followed by a catch clause, a finally
+// clause or both. Catch is nil when there is no catch clause, and Finally
+// when there is no finally clause.
+func (p *Parser) TryStatement() Stmt {
+	keyword := p.previous()
+	p.comsume(scanner.LEFT_BRACE, "Expect '{' after 'try'.")
+	body := p.Block()
+
+	var name scanner.Token
+	var catch, finally []Stmt
+	if p.match(scanner.CATCH) {
+		p.comsume(scanner.LEFT_PAREN, "Expect '(' after 'catch'.")
+		name = p.comsume(scanner.IDENTIFIER, "Expect exception variable name.")
+		p.comsume(scanner.RIGHT_PAREN, "Expect ')' after exception variable.")
+		p.comsume(scanner.LEFT_BRACE, "Expect '{' before catch body.")
+		catch = p.Block()
+	}
+	if p.match(scanner.FINALLY) {
+		p.comsume(scanner.LEFT_BRACE, "Expect '{' after 'finally'.")
+		finally = p.Block()
+	}
+	if catch == nil && finally == nil {
+		p.Error(p.peek(), "Expect 'catch' or 'finally' after try block.")
+	}
+	return &Try{keyword, body, name, catch, finally}
 }
 
 func (p *Parser) ExpressionStatement() Stmt {
 	value := p.Expression()
+	if p.AllowTrailingExpression && p.isAtEnd() {
+		return &Expression{value}
+	}
 	p.comsume(scanner.SEMICOLON, "Expect ';' after expression.")
 	return &Expression{value}
 }
@@ -152,30 +398,116 @@ func (p *Parser) Unary() Expr {
 		right := p.Unary()
 		return &Unary{operator, right}
 	}
-	return p.Primary()
+	return p.Call()
+}
+
+func (p *Parser) Call() Expr {
+	expr := p.Primary()
+	for {
+		if p.match(scanner.LEFT_PAREN) {
+			expr = p.finishCall(expr)
+		} else if p.match(scanner.DOT) {
+			name := p.comsume(scanner.IDENTIFIER, "Expect property name after '.'.")
+			expr = &Get{expr, name}
+		} else if p.match(scanner.LEFT_BRACKET) {
+			index := p.Expression()
+			bracket := p.comsume(scanner.RIGHT_BRACKET, "Expect ']' after index.")
+			expr = &Index{expr, bracket, index}
+		} else {
+			break
+		}
+	}
+	return expr
+}
+
+func (p *Parser) finishCall(callee Expr) Expr {
+	arguments := make([]Expr, 0)
+	if !p.check(scanner.RIGHT_PAREN) {
+		for {
+			if len(arguments) >= 255 {
+				p.record(p.peek(), "Can't have more than 255 arguments.")
+			}
+			arguments = append(arguments, p.Expression())
+			if !p.match(scanner.COMMA) {
+				break
+			}
+		}
+	}
+	paren := p.comsume(scanner.RIGHT_PAREN, "Expect ')' after arguments.")
+	return &Call{callee, paren, arguments}
 }
 
 func (p *Parser) Primary() Expr {
 	switch {
 	case p.match(scanner.FALSE):
-		return &Literal{false}
+		return &Literal{p.previous(), false}
 	case p.match(scanner.TRUE):
-		return &Literal{true}
+		return &Literal{p.previous(), true}
 	case p.match(scanner.NIL):
-		return &Literal{nil}
+		return &Literal{p.previous(), nil}
 	case p.match(scanner.NUMBER, scanner.STRING):
-		return &Literal{p.previous().Literal}
+		return &Literal{p.previous(), p.previous().Literal}
+	case p.match(scanner.SUPER):
+		keyword := p.previous()
+		p.comsume(scanner.DOT, "Expect '.' after 'super'.")
+		method := p.comsume(scanner.IDENTIFIER, "Expect superclass method name.")
+		return &Super{keyword, method}
+	case p.match(scanner.THIS):
+		return &This{p.previous()}
 	case p.match(scanner.IDENTIFIER):
 		return &Variable{p.previous()}
 	case p.match(scanner.LEFT_PAREN):
 		expr := p.Expression()
 		p.comsume(scanner.RIGHT_PAREN, "Expect ')' after expression.")
 		return &Grouping{expr}
+	case p.match(scanner.LEFT_BRACKET):
+		return p.List()
+	case p.match(scanner.LEFT_BRACE):
+		return p.Map()
 	}
 	p.Error(p.peek(), "Expect expression.")
 	return nil
 }
 
+func (p *Parser) List() Expr {
+	elements := make([]Expr, 0)
+	if !p.check(scanner.RIGHT_BRACKET) {
+		for {
+			if len(elements) >= 255 {
+				p.record(p.peek(), "Can't have more than 255 elements in a list literal.")
+			}
+			elements = append(elements, p.Expression())
+			if !p.match(scanner.COMMA) || p.check(scanner.RIGHT_BRACKET) {
+				break
+			}
+		}
+	}
+	bracket := p.comsume(scanner.RIGHT_BRACKET, "Expect ']' after list elements.")
+	return &List{bracket, elements}
+}
+
+// Map parses a map literal. A '{' only starts one where an expression is
+// expected; at the start of a statement it opens a block.
+func (p *Parser) Map() Expr {
+	keys := make([]Expr, 0)
+	values := make([]Expr, 0)
+	if !p.check(scanner.RIGHT_BRACE) {
+		for {
+			if len(keys) >= 255 {
+				p.record(p.peek(), "Can't have more than 255 entries in a map literal.")
+			}
+			keys = append(keys, p.Expression())
+			p.comsume(scanner.COLON, "Expect ':' after map key.")
+			values = append(values, p.Expression())
+			if !p.match(scanner.COMMA) || p.check(scanner.RIGHT_BRACE) {
+				break
+			}
+		}
+	}
+	brace := p.comsume(scanner.RIGHT_BRACE, "Expect '}' after map entries.")
+	return &Map{brace, keys, values}
+}
+
 func (p *Parser) match(tokens ...scanner.TokenType) bool {
 	for _, token := range tokens {
 		if p.check(token) {
@@ -230,22 +562,24 @@ func (p *Parser) synchronize() {
 		switch p.peek().Type {
 		case scanner.CLASS, scanner.FUN, scanner.VAR, scanner.FOR, scanner.IF:
 			return
-		case scanner.WHILE, scanner.PRINT, scanner.RETURN:
+		case scanner.WHILE, scanner.PRINT, scanner.RETURN, scanner.THROW, scanner.TRY:
 			return
 		}
 		p.advance()
 	}
 }
 
+// Error records a syntax error at token and unwinds to the enclosing
+// Declaration.
 func (p *Parser) Error(token scanner.Token, message string) {
-	if token.Type == scanner.EOF {
-		p.report(token.Line, " at end", message)
-	}
-	p.report(token.Line, "at '"+token.Lexeme+"'", message)
+	panic(p.record(token, message))
 }
 
-func (p *Parser) report(line int, where, message string) {
+// record notes a syntax error without unwinding, for errors that leave the
+// parser in a known state.
+func (p *Parser) record(token scanner.Token, message string) *ParseError {
 	p.HadError = true
-	t := fmt.Sprintf("[line %d ] Error %s: %s", line, where, message)
-	panic(errors.New(t))
+	err := &ParseError{Token: token, Message: message}
+	p.errors = append(p.errors, err)
+	return err
 }
@@ -7,9 +7,16 @@ import (
 
 type StmtVisitor interface {
 	VisitBlockStmt(*Block) any
+	VisitClassStmt(*Class) any
 	VisitExpressionStmt(*Expression) any
+	VisitFunctionStmt(*Function) any
+	VisitIfStmt(*If) any
 	VisitPrintStmt(*Print) any
+	VisitReturnStmt(*Return) any
+	VisitThrowStmt(*Throw) any
+	VisitTryStmt(*Try) any
 	VisitVarStmt(*Var) any
+	VisitWhileStmt(*While) any
 }
 
 type Stmt interface {
@@ -17,6 +24,7 @@ type Stmt interface {
 }
 
 type Block struct {
+	Brace      scanner.Token
 	Statements []Stmt
 }
 
@@ -24,6 +32,16 @@ func (i *Block) Accept(v StmtVisitor) any {
 	return v.VisitBlockStmt(i)
 }
 
+type Class struct {
+	Name       scanner.Token
+	Superclass *Variable
+	Methods    []*Function
+}
+
+func (i *Class) Accept(v StmtVisitor) any {
+	return v.VisitClassStmt(i)
+}
+
 type Expression struct {
 	Expression Expr
 }
@@ -32,7 +50,28 @@ func (i *Expression) Accept(v StmtVisitor) any {
 	return v.VisitExpressionStmt(i)
 }
 
+type Function struct {
+	Name   scanner.Token
+	Params []scanner.Token
+	Body   []Stmt
+}
+
+func (i *Function) Accept(v StmtVisitor) any {
+	return v.VisitFunctionStmt(i)
+}
+
+type If struct {
+	Condition  Expr
+	ThenBranch Stmt
+	ElseBranch Stmt
+}
+
+func (i *If) Accept(v StmtVisitor) any {
+	return v.VisitIfStmt(i)
+}
+
 type Print struct {
+	Keyword    scanner.Token
 	Expression Expr
 }
 
@@ -40,6 +79,36 @@ func (i *Print) Accept(v StmtVisitor) any {
 	return v.VisitPrintStmt(i)
 }
 
+type Return struct {
+	Keyword scanner.Token
+	Value   Expr
+}
+
+func (i *Return) Accept(v StmtVisitor) any {
+	return v.VisitReturnStmt(i)
+}
+
+type Throw struct {
+	Keyword scanner.Token
+	Value   Expr
+}
+
+func (i *Throw) Accept(v StmtVisitor) any {
+	return v.VisitThrowStmt(i)
+}
+
+type Try struct {
+	Keyword scanner.Token
+	Body    []Stmt
+	Name    scanner.Token
+	Catch   []Stmt
+	Finally []Stmt
+}
+
+func (i *Try) Accept(v StmtVisitor) any {
+	return v.VisitTryStmt(i)
+}
+
 type Var struct {
 	Name        scanner.Token
 	Initializer Expr
@@ -48,3 +117,13 @@ type Var struct {
 func (i *Var) Accept(v StmtVisitor) any {
 	return v.VisitVarStmt(i)
 }
+
+type While struct {
+	Keyword   scanner.Token
+	Condition Expr
+	Body      Stmt
+}
+
+func (i *While) Accept(v StmtVisitor) any {
+	return v.VisitWhileStmt(i)
+}
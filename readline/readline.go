@@ -0,0 +1,355 @@
+// Package readline is a small line editor for the REPL: cursor movement,
+// persistent history and tab completion on a raw-mode terminal.
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupt is returned by ReadLine when the user presses Ctrl-C.
+var ErrInterrupt = errors.New("interrupt")
+
+const maxHistory = 1000
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+type Editor struct {
+	in          *os.File
+	reader      *bufio.Reader
+	out         io.Writer
+	history     []string
+	historyFile string
+	// Complete returns the candidates that could replace word, the
+	// identifier just before the cursor.
+	Complete func(word string) []string
+
+	// state of the line being edited
+	prompt     string
+	line       []rune
+	pos        int
+	historyIdx int
+	draft      []rune
+	lastTab    bool
+}
+
+// IsTerminal reports whether f can be put into raw mode for editing.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// New creates an editor reading keys from in. History is loaded from and
+// appended to historyFile unless it is empty.
+func New(in *os.File, out io.Writer, historyFile string) *Editor {
+	e := &Editor{
+		in:          in,
+		reader:      bufio.NewReader(in),
+		out:         out,
+		historyFile: historyFile,
+	}
+	e.loadHistory()
+	return e
+}
+
+// ReadLine shows prompt and returns the edited line without its newline. It
+// returns io.EOF for Ctrl-D on an empty line and ErrInterrupt for Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(int(e.in.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(int(e.in.Fd()), state)
+
+	e.prompt = prompt
+	e.line = e.line[:0]
+	e.pos = 0
+	e.historyIdx = len(e.history)
+	e.lastTab = false
+	e.refresh()
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		tab := false
+		switch r {
+		case keyEnter, keyLineFeed:
+			e.write("\r\n")
+			return string(e.line), nil
+		case keyCtrlC:
+			e.write("^C\r\n")
+			return "", ErrInterrupt
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				e.write("\r\n")
+				return "", io.EOF
+			}
+			e.deleteForward()
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlB:
+			e.moveLeft()
+		case keyCtrlF:
+			e.moveRight()
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = append(e.line[:0], e.line[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			start := e.wordStart()
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			e.write("\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.historyPrev()
+		case keyCtrlN:
+			e.historyNext()
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+				e.pos--
+			}
+		case keyTab:
+			e.complete()
+			tab = true
+		case keyEscape:
+			e.escape()
+		default:
+			if unicode.IsPrint(r) {
+				e.insert(r)
+			}
+		}
+		e.lastTab = tab
+		e.refresh()
+	}
+}
+
+// AddHistory records line so it can be recalled, here and in later sessions.
+func (e *Editor) AddHistory(line string) {
+	line = strings.TrimRight(line, "\n")
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return
+	}
+	if len(e.history) != 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	if e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func (e *Editor) loadHistory() {
+	if e.historyFile == "" {
+		return
+	}
+	data, err := os.ReadFile(e.historyFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// escape handles the ANSI sequences sent by arrow, home, end and delete.
+func (e *Editor) escape() {
+	next, _, err := e.reader.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return
+	}
+	code, _, err := e.reader.ReadRune()
+	if err != nil {
+		return
+	}
+	switch code {
+	case 'A':
+		e.historyPrev()
+	case 'B':
+		e.historyNext()
+	case 'C':
+		e.moveRight()
+	case 'D':
+		e.moveLeft()
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.line)
+	case '1', '3', '4', '7', '8':
+		// ESC [ n ~
+		if tilde, _, err := e.reader.ReadRune(); err != nil || tilde != '~' {
+			return
+		}
+		switch code {
+		case '1', '7':
+			e.pos = 0
+		case '4', '8':
+			e.pos = len(e.line)
+		case '3':
+			e.deleteForward()
+		}
+	}
+}
+
+func (e *Editor) insert(r rune) {
+	e.line = append(e.line, 0)
+	copy(e.line[e.pos+1:], e.line[e.pos:])
+	e.line[e.pos] = r
+	e.pos++
+}
+
+func (e *Editor) deleteForward() {
+	if e.pos < len(e.line) {
+		e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+	}
+}
+
+func (e *Editor) moveLeft() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+func (e *Editor) moveRight() {
+	if e.pos < len(e.line) {
+		e.pos++
+	}
+}
+
+func (e *Editor) historyPrev() {
+	if e.historyIdx == 0 {
+		return
+	}
+	if e.historyIdx == len(e.history) {
+		e.draft = append(e.draft[:0], e.line...)
+	}
+	e.historyIdx--
+	e.setLine([]rune(e.history[e.historyIdx]))
+}
+
+func (e *Editor) historyNext() {
+	if e.historyIdx == len(e.history) {
+		return
+	}
+	e.historyIdx++
+	if e.historyIdx == len(e.history) {
+		e.setLine(e.draft)
+		return
+	}
+	e.setLine([]rune(e.history[e.historyIdx]))
+}
+
+func (e *Editor) setLine(line []rune) {
+	e.line = append(e.line[:0], line...)
+	e.pos = len(e.line)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (e *Editor) wordStart() int {
+	start := e.pos
+	for start > 0 && isWordRune(e.line[start-1]) {
+		start--
+	}
+	// a ':' opening the line starts a meta-command such as :load; anywhere
+	// else it is punctuation, as in a map literal
+	if start == 1 && e.line[0] == ':' {
+		start = 0
+	}
+	return start
+}
+
+// complete extends the word before the cursor to the longest prefix shared
+// by its candidates. A second Tab with nothing left to add lists them.
+func (e *Editor) complete() {
+	if e.Complete == nil {
+		return
+	}
+	start := e.wordStart()
+	word := string(e.line[start:e.pos])
+	candidates := e.Complete(word)
+	if len(candidates) == 0 {
+		e.write("\a")
+		return
+	}
+
+	// shorten by runes so as not to split a multi-byte character
+	prefix := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if length := e.pos - start; len(prefix) > length {
+		for _, r := range prefix[length:] {
+			e.insert(r)
+		}
+		return
+	}
+	if len(candidates) > 1 && e.lastTab {
+		e.write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+	}
+}
+
+func (e *Editor) refresh() {
+	builder := strings.Builder{}
+	builder.WriteString("\r")
+	builder.WriteString(e.prompt)
+	builder.WriteString(string(e.line))
+	builder.WriteString("\x1b[K")
+	if back := len(e.line) - e.pos; back > 0 {
+		fmt.Fprintf(&builder, "\x1b[%dD", back)
+	}
+	e.write(builder.String())
+}
+
+func (e *Editor) write(s string) {
+	io.WriteString(e.out, s)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"craftinginterpreters/lox/diagnostics"
+	"craftinginterpreters/lox/interpreter"
+	"craftinginterpreters/lox/lox"
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/readline"
+	"craftinginterpreters/lox/scanner"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt             = ">> "
+	continuationPrompt = ".. "
+)
+
+// RunPrompt reads statements until EOF. Input is buffered across lines while
+// brackets or a string literal are still open. On a terminal lines can be
+// edited, recalled from history and completed with Tab.
+func (l *Lox) RunPrompt() {
+	var input lineReader
+	if readline.IsTerminal(os.Stdin) {
+		editor := readline.New(os.Stdin, os.Stdout, historyFile())
+		editor.Complete = l.complete
+		input = editor
+	} else {
+		input = &plainReader{bufio.NewScanner(os.Stdin)}
+	}
+
+	var buffer strings.Builder
+	for {
+		p := prompt
+		if buffer.Len() != 0 {
+			p = continuationPrompt
+		}
+		line, err := input.ReadLine(p)
+		if errors.Is(err, readline.ErrInterrupt) {
+			buffer.Reset()
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			break
+		}
+		if editor, ok := input.(*readline.Editor); ok {
+			editor.AddHistory(line)
+		}
+
+		if buffer.Len() == 0 && strings.HasPrefix(line, ":") {
+			l.runCommand(line)
+			continue
+		}
+		buffer.WriteString(line)
+		buffer.WriteString("\n")
+		if needsMoreInput(buffer.String()) {
+			continue
+		}
+		l.runLine(buffer.String(), true)
+		buffer.Reset()
+	}
+	if buffer.Len() != 0 {
+		l.runLine(buffer.String(), true)
+	}
+}
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader reads lines without editing, for input that is not a terminal.
+type plainReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if !r.scanner.Scan() {
+		fmt.Println()
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lox_history")
+}
+
+// complete offers keywords and global names, or meta-commands for a word
+// starting with ':'.
+func (l *Lox) complete(word string) []string {
+	names := make([]string, 0)
+	if strings.HasPrefix(word, ":") {
+		for _, c := range commands {
+			names = append(names, c.name)
+		}
+	} else {
+		for keyword := range scanner.Keywords {
+			names = append(names, keyword)
+		}
+		for name := range l.runtime.Globals() {
+			names = append(names, name)
+		}
+	}
+
+	res := make([]string, 0)
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (l *Lox) runLine(line string, echo bool) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	l.run(line, echo)
+	l.hadError = false
+	l.hadRuntimeError = false
+}
+
+// needsMoreInput reports whether source ends inside an unclosed bracket or
+// string literal.
+func needsMoreInput(source string) bool {
+	s := scanner.New()
+	depth := 0
+	for _, token := range s.ScanAll(source) {
+		switch token.Type {
+		case scanner.LEFT_PAREN, scanner.LEFT_BRACE, scanner.LEFT_BRACKET:
+			depth++
+		case scanner.RIGHT_PAREN, scanner.RIGHT_BRACE, scanner.RIGHT_BRACKET:
+			depth--
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+	if errs, ok := s.Err().(scanner.ScanErrors); ok {
+		for _, err := range errs {
+			if err.Message == "Unterminated string." {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(l *Lox, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{":help", ":help", "list the meta-commands", (*Lox).helpCommand},
+		{":env", ":env", "show the global variables", (*Lox).envCommand},
+		{":ast", ":ast <expr>", "show the syntax tree of an expression", (*Lox).astCommand},
+		{":tokens", ":tokens <src>", "show the tokens scanned from src", (*Lox).tokensCommand},
+		{":load", ":load <file>", "run a file in the current session", (*Lox).loadCommand},
+		{":reset", ":reset", "discard all global variables", (*Lox).resetCommand},
+	}
+}
+
+func (l *Lox) runCommand(line string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	for _, c := range commands {
+		if c.name == name {
+			c.run(l, strings.TrimSpace(arg))
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %s, try :help.\n", name)
+}
+
+func (l *Lox) helpCommand(string) {
+	for _, c := range commands {
+		fmt.Printf("%-16s %s\n", c.usage, c.help)
+	}
+}
+
+func (l *Lox) envCommand(string) {
+	values := l.runtime.Globals()
+	names := make([]string, 0, len(values))
+	for name, value := range values {
+		// built-in functions would drown out the script's own globals
+		if _, ok := value.(*interpreter.NativeFunction); ok {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s = %s\n", name, lox.Format(values[name]))
+	}
+}
+
+func (l *Lox) astCommand(arg string) {
+	s := scanner.New()
+	tokens := s.ScanAll(arg)
+	if err := s.Err(); err != nil {
+		reportIn(arg, err)
+		return
+	}
+	expr, err := parser.NewParser(tokens).ParseExpression()
+	if err != nil {
+		reportIn(arg, err)
+		return
+	}
+	fmt.Println(parser.AstPrinter{}.Print(expr))
+}
+
+func (l *Lox) tokensCommand(arg string) {
+	s := scanner.New()
+	for _, token := range s.ScanAll(arg) {
+		fmt.Printf("%s %s\n", token.Start, token.String())
+	}
+	if err := s.Err(); err != nil {
+		reportIn(arg, err)
+	}
+}
+
+// reportIn reports an error found in the argument of a meta-command.
+func reportIn(source string, err error) {
+	if !diagnostics.RenderAll(os.Stderr, "", source, err) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (l *Lox) loadCommand(arg string) {
+	if arg == "" {
+		fmt.Fprintln(os.Stderr, "Usage: :load <file>")
+		return
+	}
+	_, err := l.runtime.EvalFile(arg)
+	l.check(err)
+	l.hadError = false
+	l.hadRuntimeError = false
+}
+
+func (l *Lox) resetCommand(string) {
+	*l = *newLox(l.useVM)
+}
@@ -0,0 +1,30 @@
+package resolver
+
+import (
+	"craftinginterpreters/lox/diagnostics"
+	"craftinginterpreters/lox/scanner"
+	"fmt"
+)
+
+// ResolveError is a static error found while resolving variable scopes.
+type ResolveError struct {
+	Token   scanner.Token
+	Message string
+}
+
+func (e *ResolveError) Error() string {
+	where := "at '" + e.Token.Lexeme + "'"
+	if e.Token.Type == scanner.EOF {
+		where = "at end"
+	}
+	return fmt.Sprintf("[line %s ] Error %s: %s", e.Token.Start, where, e.Message)
+}
+
+func (e *ResolveError) Diagnostics() []diagnostics.Diagnostic {
+	return []diagnostics.Diagnostic{{
+		Code:    diagnostics.CodeResolution,
+		Message: e.Message,
+		Start:   e.Token.Start.Offset,
+		End:     e.Token.End.Offset,
+	}}
+}
@@ -0,0 +1,369 @@
+package resolver
+
+import (
+	"craftinginterpreters/lox/interpreter"
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+	"fmt"
+)
+
+var _ parser.ExprVisitor = &Resolver{}
+var _ parser.StmtVisitor = &Resolver{}
+
+type FunctionType int
+
+const (
+	NONE FunctionType = iota
+	FUNCTION
+	INITIALIZER
+	METHOD
+)
+
+type ClassType int
+
+const (
+	NONE_CLASS ClassType = iota
+	CLASS
+	SUBCLASS
+)
+
+type Resolver struct {
+	interpreter     *interpreter.Interpreter
+	scopes          []map[string]bool
+	currentFunction FunctionType
+	currentClass    ClassType
+	HadError        bool
+}
+
+// NewResolver creates a resolver that reports scope depths to interpreter.
+// interpreter may be nil when only the static checks are wanted.
+func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
+	return &Resolver{
+		interpreter: interpreter,
+		scopes:      make([]map[string]bool, 0),
+	}
+}
+
+func (r *Resolver) Resolve(statements []parser.Stmt) (err error) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			if rerr, ok := terr.(*ResolveError); ok {
+				err = rerr
+				return
+			}
+			err = fmt.Errorf("internal resolver error: %v", terr)
+		}
+	}()
+	r.resolveStmts(statements)
+	return nil
+}
+
+func (r *Resolver) VisitBlockStmt(b *parser.Block) any {
+	r.beginScope()
+	r.resolveStmts(b.Statements)
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitClassStmt(c *parser.Class) any {
+	enclosingClass := r.currentClass
+	r.currentClass = CLASS
+	defer func() {
+		r.currentClass = enclosingClass
+	}()
+
+	r.declare(c.Name)
+	r.define(c.Name)
+
+	if c.Superclass != nil {
+		if c.Name.Lexeme == c.Superclass.Name.Lexeme {
+			r.Error(c.Superclass.Name, "A class can't inherit from itself.")
+		}
+		r.currentClass = SUBCLASS
+		r.resolveExpr(c.Superclass)
+
+		r.beginScope()
+		r.peekScope()["super"] = true
+	}
+
+	r.beginScope()
+	r.peekScope()["this"] = true
+	for _, method := range c.Methods {
+		declaration := METHOD
+		if method.Name.Lexeme == "init" {
+			declaration = INITIALIZER
+		}
+		r.resolveFunction(method, declaration)
+	}
+	r.endScope()
+
+	if c.Superclass != nil {
+		r.endScope()
+	}
+	return nil
+}
+
+func (r *Resolver) VisitExpressionStmt(e *parser.Expression) any {
+	r.resolveExpr(e.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitFunctionStmt(f *parser.Function) any {
+	r.declare(f.Name)
+	r.define(f.Name)
+	r.resolveFunction(f, FUNCTION)
+	return nil
+}
+
+func (r *Resolver) VisitIfStmt(i *parser.If) any {
+	r.resolveExpr(i.Condition)
+	r.resolveStmt(i.ThenBranch)
+	if i.ElseBranch != nil {
+		r.resolveStmt(i.ElseBranch)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitPrintStmt(p *parser.Print) any {
+	r.resolveExpr(p.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitReturnStmt(s *parser.Return) any {
+	if r.currentFunction == NONE {
+		r.Error(s.Keyword, "Can't return from top-level code.")
+	}
+	if s.Value != nil {
+		if r.currentFunction == INITIALIZER {
+			r.Error(s.Keyword, "Can't return a value from an initializer.")
+		}
+		r.resolveExpr(s.Value)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitThrowStmt(t *parser.Throw) any {
+	r.resolveExpr(t.Value)
+	return nil
+}
+
+// VisitTryStmt resolves each clause in its own scope. The caught value is
+// bound in the scope of the catch body, like a parameter.
+func (r *Resolver) VisitTryStmt(t *parser.Try) any {
+	r.beginScope()
+	r.resolveStmts(t.Body)
+	r.endScope()
+	if t.Catch != nil {
+		r.beginScope()
+		r.declare(t.Name)
+		r.define(t.Name)
+		r.resolveStmts(t.Catch)
+		r.endScope()
+	}
+	if t.Finally != nil {
+		r.beginScope()
+		r.resolveStmts(t.Finally)
+		r.endScope()
+	}
+	return nil
+}
+
+func (r *Resolver) VisitVarStmt(v *parser.Var) any {
+	r.declare(v.Name)
+	if v.Initializer != nil {
+		r.resolveExpr(v.Initializer)
+	}
+	r.define(v.Name)
+	return nil
+}
+
+func (r *Resolver) VisitWhileStmt(w *parser.While) any {
+	r.resolveExpr(w.Condition)
+	r.resolveStmt(w.Body)
+	return nil
+}
+
+func (r *Resolver) VisitAssignExpr(a *parser.Assign) any {
+	r.resolveExpr(a.Value)
+	r.resolveLocal(a, a.Name)
+	return nil
+}
+
+func (r *Resolver) VisitBinaryExpr(b *parser.Binary) any {
+	r.resolveExpr(b.Left)
+	r.resolveExpr(b.Right)
+	return nil
+}
+
+func (r *Resolver) VisitCallExpr(c *parser.Call) any {
+	r.resolveExpr(c.Callee)
+	for _, argument := range c.Arguments {
+		r.resolveExpr(argument)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitGetExpr(g *parser.Get) any {
+	r.resolveExpr(g.Object)
+	return nil
+}
+
+func (r *Resolver) VisitGroupingExpr(g *parser.Grouping) any {
+	r.resolveExpr(g.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitIndexExpr(i *parser.Index) any {
+	r.resolveExpr(i.Object)
+	r.resolveExpr(i.Index)
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(l *parser.List) any {
+	for _, element := range l.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitLiteralExpr(l *parser.Literal) any {
+	return nil
+}
+
+func (r *Resolver) VisitLogicalExpr(l *parser.Logical) any {
+	r.resolveExpr(l.Left)
+	r.resolveExpr(l.Right)
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(m *parser.Map) any {
+	for idx := range m.Keys {
+		r.resolveExpr(m.Keys[idx])
+		r.resolveExpr(m.Values[idx])
+	}
+	return nil
+}
+
+func (r *Resolver) VisitSetExpr(s *parser.Set) any {
+	r.resolveExpr(s.Value)
+	r.resolveExpr(s.Object)
+	return nil
+}
+
+func (r *Resolver) VisitSetIndexExpr(s *parser.SetIndex) any {
+	r.resolveExpr(s.Value)
+	r.resolveExpr(s.Object)
+	r.resolveExpr(s.Index)
+	return nil
+}
+
+func (r *Resolver) VisitSuperExpr(s *parser.Super) any {
+	if r.currentClass == NONE_CLASS {
+		r.Error(s.Keyword, "Can't use 'super' outside of a class.")
+	} else if r.currentClass != SUBCLASS {
+		r.Error(s.Keyword, "Can't use 'super' in a class with no superclass.")
+	}
+	r.resolveLocal(s, s.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitThisExpr(t *parser.This) any {
+	if r.currentClass == NONE_CLASS {
+		r.Error(t.Keyword, "Can't use 'this' outside of a class.")
+	}
+	r.resolveLocal(t, t.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitUnaryExpr(u *parser.Unary) any {
+	r.resolveExpr(u.Right)
+	return nil
+}
+
+func (r *Resolver) VisitVariableExpr(v *parser.Variable) any {
+	if len(r.scopes) != 0 {
+		if defined, ok := r.peekScope()[v.Name.Lexeme]; ok && !defined {
+			r.Error(v.Name, "Can't read local variable in its own initializer.")
+		}
+	}
+	r.resolveLocal(v, v.Name)
+	return nil
+}
+
+func (r *Resolver) resolveStmts(statements []parser.Stmt) {
+	for _, statement := range statements {
+		r.resolveStmt(statement)
+	}
+}
+
+func (r *Resolver) resolveStmt(stmt parser.Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *Resolver) resolveExpr(expr parser.Expr) {
+	expr.Accept(r)
+}
+
+func (r *Resolver) resolveFunction(function *parser.Function, t FunctionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = t
+	defer func() {
+		r.currentFunction = enclosingFunction
+	}()
+
+	r.beginScope()
+	for _, param := range function.Params {
+		r.declare(param)
+		r.define(param)
+	}
+	r.resolveStmts(function.Body)
+	r.endScope()
+}
+
+// resolveLocal tells the interpreter how many scopes lie between the use of
+// name and its declaration. Unresolved names are assumed to be global.
+func (r *Resolver) resolveLocal(expr parser.Expr, name scanner.Token) {
+	for idx := len(r.scopes) - 1; idx >= 0; idx-- {
+		if _, ok := r.scopes[idx][name.Lexeme]; ok {
+			if r.interpreter != nil {
+				r.interpreter.Resolve(expr, len(r.scopes)-1-idx)
+			}
+			return
+		}
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, map[string]bool{})
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) peekScope() map[string]bool {
+	return r.scopes[len(r.scopes)-1]
+}
+
+func (r *Resolver) declare(name scanner.Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	scope := r.peekScope()
+	if _, ok := scope[name.Lexeme]; ok {
+		r.Error(name, "Already a variable with this name in this scope.")
+	}
+	scope[name.Lexeme] = false
+}
+
+func (r *Resolver) define(name scanner.Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.peekScope()[name.Lexeme] = true
+}
+
+func (r *Resolver) Error(token scanner.Token, message string) {
+	r.HadError = true
+	panic(&ResolveError{Token: token, Message: message})
+}
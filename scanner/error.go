@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"craftinginterpreters/lox/diagnostics"
+	"fmt"
+	"strings"
+)
+
+// ScanError is a lexical error covering the source between Start and End.
+type ScanError struct {
+	Start   Position
+	End     Position
+	Message string
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("[line %s ] Error: %s", e.Start, e.Message)
+}
+
+func (e *ScanError) Diagnostics() []diagnostics.Diagnostic {
+	return []diagnostics.Diagnostic{{
+		Code:    diagnostics.CodeLexical,
+		Message: e.Message,
+		Start:   e.Start.Offset,
+		End:     e.End.Offset,
+	}}
+}
+
+// ScanErrors collects every lexical error found in one ScanAll call.
+type ScanErrors []*ScanError
+
+func (e ScanErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e ScanErrors) Diagnostics() []diagnostics.Diagnostic {
+	res := make([]diagnostics.Diagnostic, 0, len(e))
+	for _, err := range e {
+		res = append(res, err.Diagnostics()...)
+	}
+	return res
+}
+
+// As lets errors.As find the first *ScanError in the collection.
+func (e ScanErrors) As(target any) bool {
+	if t, ok := target.(**ScanError); ok && len(e) != 0 {
+		*t = e[0]
+		return true
+	}
+	return false
+}
@@ -1,30 +1,34 @@
 package scanner
 
 import (
-	"fmt"
 	"strconv"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/samber/lo"
 )
 
 var Keywords = map[string]TokenType{
-	"and":    AND,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":     AND,
+	"catch":   CATCH,
+	"class":   CLASS,
+	"else":    ELSE,
+	"false":   FALSE,
+	"finally": FINALLY,
+	"for":     FOR,
+	"fun":     FUN,
+	"if":      IF,
+	"nil":     NIL,
+	"or":      OR,
+	"print":   PRINT,
+	"return":  RETURN,
+	"super":   SUPER,
+	"this":    THIS,
+	"throw":   THROW,
+	"true":    TRUE,
+	"try":     TRY,
+	"var":     VAR,
+	"while":   WHILE,
 }
 
 type Scanner struct {
@@ -32,13 +36,18 @@ type Scanner struct {
 	start    int
 	current  int
 	line     int
+	column   int
+	offset   int
+	startPos Position
 	tokens   []Token
 	hadError bool
+	errors   ScanErrors
 }
 
 func New() *Scanner {
 	return &Scanner{
 		line:   1,
+		column: 1,
 		tokens: make([]Token, 0),
 	}
 }
@@ -49,9 +58,18 @@ func (s *Scanner) ScanAll(source string) []Token {
 	return s.tokens
 }
 
+// Err returns the lexical errors found by ScanAll, or nil.
+func (s *Scanner) Err() error {
+	if len(s.errors) == 0 {
+		return nil
+	}
+	return s.errors
+}
+
 func (s *Scanner) scanTokens() {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startPos = s.position()
 		s.scanToken()
 	}
 	s.tokens = append(s.tokens, Token{
@@ -59,6 +77,8 @@ func (s *Scanner) scanTokens() {
 		Lexeme:  "",
 		Literal: nil,
 		Line:    s.line,
+		Start:   s.position(),
+		End:     s.position(),
 	})
 }
 
@@ -73,8 +93,14 @@ func (s *Scanner) scanToken() {
 		s.addToken(LEFT_BRACE, nil)
 	case '}':
 		s.addToken(RIGHT_BRACE, nil)
+	case '[':
+		s.addToken(LEFT_BRACKET, nil)
+	case ']':
+		s.addToken(RIGHT_BRACKET, nil)
 	case ',':
 		s.addToken(COMMA, nil)
+	case ':':
+		s.addToken(COLON, nil)
 	case '.':
 		s.addToken(DOT, nil)
 	case '-':
@@ -101,10 +127,8 @@ func (s *Scanner) scanToken() {
 		} else {
 			s.addToken(SLASH, nil)
 		}
-	case ' ', '\r', '\t':
+	case ' ', '\r', '\t', '\n':
 		break
-	case '\n':
-		s.line++
 	case '"':
 		s.string()
 	default:
@@ -113,20 +137,17 @@ func (s *Scanner) scanToken() {
 		} else if s.isAlpha(c) {
 			s.identifier()
 		} else {
-			s.Error(s.line, "Unexpected character.")
+			s.Error(s.startPos, "Unexpected character.")
 		}
 	}
 }
 
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		s.advance()
 	}
 	if s.isAtEnd() {
-		s.Error(s.line, "Unterminated string.")
+		s.Error(s.startPos, "Unterminated string.")
 		return
 	}
 	s.advance()
@@ -180,8 +201,24 @@ func (s *Scanner) isAtEnd() bool {
 }
 
 func (s *Scanner) advance() rune {
+	c := s.source[s.current]
 	s.current++
-	return rune(s.source[s.current-1])
+	s.offset += utf8.RuneLen(c)
+	if c == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+	return c
+}
+
+func (s *Scanner) position() Position {
+	return Position{
+		Line:   s.line,
+		Column: s.column,
+		Offset: s.offset,
+	}
 }
 
 func (s *Scanner) addToken(tokenType TokenType, literal any) {
@@ -190,7 +227,9 @@ func (s *Scanner) addToken(tokenType TokenType, literal any) {
 		Type:    tokenType,
 		Lexeme:  string(text),
 		Literal: literal,
-		Line:    s.line,
+		Line:    s.startPos.Line,
+		Start:   s.startPos,
+		End:     s.position(),
 	})
 }
 
@@ -201,7 +240,7 @@ func (s *Scanner) match(c rune) bool {
 	if rune(s.source[s.current]) != c {
 		return false
 	}
-	s.current++
+	s.advance()
 	return true
 }
 
@@ -219,11 +258,11 @@ func (s *Scanner) peekNext() rune {
 	return rune(s.source[s.current+1])
 }
 
-func (s *Scanner) Error(line int, message string) {
-	s.report(line, "", message)
-}
-
-func (s *Scanner) report(line int, where, message string) {
-	fmt.Printf("[line %d ] Error %s: %s", line, where, message)
+func (s *Scanner) Error(pos Position, message string) {
 	s.hadError = true
+	s.errors = append(s.errors, &ScanError{
+		Start:   pos,
+		End:     s.position(),
+		Message: message,
+	})
 }
@@ -8,17 +8,20 @@ const (
 	ILEGAL TokenType = iota
 	// Single-character tokens.
 
-	LEFT_PAREN  // (
-	RIGHT_PAREN // )
-	LEFT_BRACE  //{
-	RIGHT_BRACE //}
-	COMMA       // ,
-	DOT         // .
-	MINUS       // -
-	PLUS        // +
-	SEMICOLON   // ;
-	SLASH       // /
-	STAR        // *
+	LEFT_PAREN    // (
+	RIGHT_PAREN   // )
+	LEFT_BRACE    //{
+	RIGHT_BRACE   //}
+	LEFT_BRACKET  // [
+	RIGHT_BRACKET // ]
+	COMMA         // ,
+	COLON         // :
+	DOT           // .
+	MINUS         // -
+	PLUS          // +
+	SEMICOLON     // ;
+	SLASH         // /
+	STAR          // *
 
 	// One or two character tokens.
 
@@ -38,9 +41,11 @@ const (
 
 	// Keywords.
 	AND
+	CATCH
 	CLASS
 	ELSE
 	FALSE
+	FINALLY
 	FUN
 	FOR
 	IF
@@ -50,18 +55,93 @@ const (
 	RETURN
 	SUPER
 	THIS
+	THROW
 	TRUE
+	TRY
 	VAR
 	WHILE
 
 	EOF
 )
 
+var tokenTypeNames = [...]string{
+	ILEGAL:        "ILEGAL",
+	LEFT_PAREN:    "LEFT_PAREN",
+	RIGHT_PAREN:   "RIGHT_PAREN",
+	LEFT_BRACE:    "LEFT_BRACE",
+	RIGHT_BRACE:   "RIGHT_BRACE",
+	LEFT_BRACKET:  "LEFT_BRACKET",
+	RIGHT_BRACKET: "RIGHT_BRACKET",
+	COMMA:         "COMMA",
+	COLON:         "COLON",
+	DOT:           "DOT",
+	MINUS:         "MINUS",
+	PLUS:          "PLUS",
+	SEMICOLON:     "SEMICOLON",
+	SLASH:         "SLASH",
+	STAR:          "STAR",
+	BANG:          "BANG",
+	BANG_EQUAL:    "BANG_EQUAL",
+	EQUAL:         "EQUAL",
+	EQUAL_EQUAL:   "EQUAL_EQUAL",
+	GREATER:       "GREATER",
+	GREATER_EQUAL: "GREATER_EQUAL",
+	LESS:          "LESS",
+	LESS_EQUAL:    "LESS_EQUAL",
+	IDENTIFIER:    "IDENTIFIER",
+	STRING:        "STRING",
+	NUMBER:        "NUMBER",
+	AND:           "AND",
+	CATCH:         "CATCH",
+	CLASS:         "CLASS",
+	ELSE:          "ELSE",
+	FALSE:         "FALSE",
+	FINALLY:       "FINALLY",
+	FUN:           "FUN",
+	FOR:           "FOR",
+	IF:            "IF",
+	NIL:           "NIL",
+	OR:            "OR",
+	PRINT:         "PRINT",
+	RETURN:        "RETURN",
+	SUPER:         "SUPER",
+	THIS:          "THIS",
+	THROW:         "THROW",
+	TRUE:          "TRUE",
+	TRY:           "TRY",
+	VAR:           "VAR",
+	WHILE:         "WHILE",
+	EOF:           "EOF",
+}
+
+func (t TokenType) String() string {
+	if int(t) < len(tokenTypeNames) {
+		return tokenTypeNames[t]
+	}
+	return fmt.Sprintf("TokenType(%d)", int(t))
+}
+
+// Position is a location in the source text. Line and Column count from 1,
+// Column in runes; Offset is the 0-based byte offset.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal any
-	Line    int
+	// Line is the line the token starts on, the same as Start.Line.
+	Line int
+	// Start and End delimit the lexeme; End is exclusive.
+	Start Position
+	End   Position
 }
 
 func (t *Token) String() string {
@@ -0,0 +1,94 @@
+package vm
+
+import "craftinginterpreters/lox/scanner"
+
+type OpCode byte
+
+const (
+	OP_CONSTANT OpCode = iota
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_GLOBAL
+	OP_DEFINE_GLOBAL
+	OP_SET_GLOBAL
+	OP_GET_UPVALUE
+	OP_SET_UPVALUE
+	OP_GET_PROPERTY
+	OP_SET_PROPERTY
+	OP_GET_SUPER
+	OP_BUILD_LIST
+	OP_BUILD_MAP
+	OP_GET_INDEX
+	OP_SET_INDEX
+	OP_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NOT
+	OP_NEGATE
+	OP_PRINT
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+	OP_CALL
+	OP_CLOSURE
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_TRY
+	OP_END_TRY
+	OP_THROW
+	OP_CLASS
+	OP_INHERIT
+	OP_METHOD
+)
+
+// tokenStart marks the first byte of a run of instructions compiled from the
+// same source token.
+type tokenStart struct {
+	offset int
+	token  scanner.Token
+}
+
+type Chunk struct {
+	Code      []byte
+	Constants []Value
+	tokens    []tokenStart
+}
+
+func (c *Chunk) Write(b byte, token scanner.Token) {
+	if len(c.tokens) == 0 || c.tokens[len(c.tokens)-1].token.Start != token.Start {
+		c.tokens = append(c.tokens, tokenStart{offset: len(c.Code), token: token})
+	}
+	c.Code = append(c.Code, b)
+}
+
+func (c *Chunk) AddConstant(value Value) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// Token returns the source token the instruction at offset was compiled from.
+func (c *Chunk) Token(offset int) scanner.Token {
+	if len(c.tokens) == 0 {
+		return scanner.Token{}
+	}
+	lo, hi := 0, len(c.tokens)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if c.tokens[mid].offset > offset {
+			hi = mid - 1
+		} else {
+			lo = mid
+		}
+	}
+	return c.tokens[lo].token
+}
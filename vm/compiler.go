@@ -0,0 +1,768 @@
+package vm
+
+import (
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+	"fmt"
+	"math"
+
+	"github.com/samber/lo"
+)
+
+var _ parser.ExprVisitor = &Compiler{}
+var _ parser.StmtVisitor = &Compiler{}
+
+type FunctionType int
+
+const (
+	TYPE_FUNCTION FunctionType = iota
+	TYPE_INITIALIZER
+	TYPE_METHOD
+	TYPE_SCRIPT
+)
+
+const maxLocals = math.MaxUint8 + 1
+
+type local struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+type upvalue struct {
+	index   uint8
+	isLocal bool
+}
+
+// funcState holds the compile-time state of the function being compiled.
+type funcState struct {
+	enclosing  *funcState
+	function   *Function
+	funcType   FunctionType
+	locals     []local
+	upvalues   []upvalue
+	scopeDepth int
+	// tries are the try statements whose handlers are active, innermost
+	// last. A return leaves them and runs their finally clauses.
+	tries []tryState
+}
+
+type tryState struct {
+	finally []parser.Stmt
+}
+
+type classState struct {
+	enclosing     *classState
+	hasSuperclass bool
+}
+
+// Compiler lowers a resolved syntax tree into bytecode. Locals are assigned
+// stack slots here, so the compiler does not depend on the resolver's depths.
+type Compiler struct {
+	current      *funcState
+	currentClass *classState
+	token        scanner.Token
+	file         string
+}
+
+func Compile(statements []parser.Stmt) (function *Function, err error) {
+	return CompileFile("", statements)
+}
+
+// CompileFile is like Compile, recording file as the source of every
+// function it compiles.
+func CompileFile(file string, statements []parser.Stmt) (function *Function, err error) {
+	return compile(file, statements, false)
+}
+
+// compile compiles a script. With keepValue set it returns the value of its
+// last statement if that is an expression statement.
+func compile(file string, statements []parser.Stmt, keepValue bool) (function *Function, err error) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			function = nil
+			if perr, ok := terr.(*parser.ParseError); ok {
+				err = perr
+				return
+			}
+			err = fmt.Errorf("internal compiler error: %v", terr)
+		}
+	}()
+	c := &Compiler{file: file}
+	c.beginFunction(&Function{}, TYPE_SCRIPT)
+	if len(statements) == 0 {
+		return c.endFunction(), nil
+	}
+	last, ok := statements[len(statements)-1].(*parser.Expression)
+	if !keepValue || !ok {
+		c.compileStmts(statements)
+		return c.endFunction(), nil
+	}
+	c.compileStmts(statements[:len(statements)-1])
+	c.compileExpr(last.Expression)
+	c.emitOp(OP_RETURN)
+	return c.endFunction(), nil
+}
+
+// CompileExpression compiles a script that evaluates expr and returns its
+// value.
+func CompileExpression(expr parser.Expr) (function *Function, err error) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			function = nil
+			if perr, ok := terr.(*parser.ParseError); ok {
+				err = perr
+				return
+			}
+			err = fmt.Errorf("internal compiler error: %v", terr)
+		}
+	}()
+	c := &Compiler{}
+	c.beginFunction(&Function{}, TYPE_SCRIPT)
+	c.compileExpr(expr)
+	c.emitOp(OP_RETURN)
+	return c.endFunction(), nil
+}
+
+func (c *Compiler) beginFunction(function *Function, t FunctionType) {
+	function.File = c.file
+	state := &funcState{
+		enclosing: c.current,
+		function:  function,
+		funcType:  t,
+	}
+	// slot zero holds the callee, or the receiver for methods
+	slotZero := ""
+	if t == TYPE_METHOD || t == TYPE_INITIALIZER {
+		slotZero = "this"
+	}
+	state.locals = append(state.locals, local{name: slotZero})
+	c.current = state
+}
+
+func (c *Compiler) endFunction() *Function {
+	c.emitReturn()
+	function := c.current.function
+	function.UpvalueCount = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return function
+}
+
+func (c *Compiler) function(f *parser.Function, t FunctionType) {
+	c.beginFunction(&Function{Name: f.Name.Lexeme, Arity: len(f.Params)}, t)
+	c.beginScope()
+	for _, param := range f.Params {
+		c.token = param
+		c.addLocal(param.Lexeme)
+	}
+	c.compileStmts(f.Body)
+	upvalues := c.current.upvalues
+	function := c.endFunction()
+
+	c.token = f.Name
+	c.emitOpShort(OP_CLOSURE, c.makeConstant(ObjValue(function)))
+	for _, uv := range upvalues {
+		if uv.isLocal {
+			c.emitByte(1)
+		} else {
+			c.emitByte(0)
+		}
+		c.emitByte(uv.index)
+	}
+}
+
+func (c *Compiler) method(m *parser.Function) {
+	name := c.identifierConstant(m.Name.Lexeme)
+	t := TYPE_METHOD
+	if m.Name.Lexeme == "init" {
+		t = TYPE_INITIALIZER
+	}
+	c.function(m, t)
+	c.emitOpShort(OP_METHOD, name)
+}
+
+func (c *Compiler) VisitBlockStmt(b *parser.Block) any {
+	c.beginScope()
+	c.compileStmts(b.Statements)
+	c.endScope()
+	return nil
+}
+
+func (c *Compiler) VisitClassStmt(s *parser.Class) any {
+	c.token = s.Name
+	name := c.identifierConstant(s.Name.Lexeme)
+	c.declareVariable(s.Name.Lexeme)
+	c.emitOpShort(OP_CLASS, name)
+	c.defineVariable(name)
+
+	class := &classState{enclosing: c.currentClass}
+	c.currentClass = class
+	defer func() {
+		c.currentClass = class.enclosing
+	}()
+
+	if s.Superclass != nil {
+		c.VisitVariableExpr(s.Superclass)
+		c.beginScope()
+		c.addLocal("super")
+
+		c.namedVariable(s.Name, false)
+		c.token = s.Superclass.Name
+		c.emitOp(OP_INHERIT)
+		class.hasSuperclass = true
+	}
+
+	c.namedVariable(s.Name, false)
+	for _, method := range s.Methods {
+		c.method(method)
+	}
+	c.emitOp(OP_POP)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+	return nil
+}
+
+func (c *Compiler) VisitExpressionStmt(e *parser.Expression) any {
+	c.compileExpr(e.Expression)
+	c.emitOp(OP_POP)
+	return nil
+}
+
+func (c *Compiler) VisitFunctionStmt(f *parser.Function) any {
+	c.token = f.Name
+	var global uint16
+	if c.current.scopeDepth == 0 {
+		global = c.identifierConstant(f.Name.Lexeme)
+	} else {
+		// declared before the body so the function can refer to itself
+		c.addLocal(f.Name.Lexeme)
+	}
+	c.function(f, TYPE_FUNCTION)
+	c.defineVariable(global)
+	return nil
+}
+
+func (c *Compiler) VisitIfStmt(s *parser.If) any {
+	c.compileExpr(s.Condition)
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileStmt(s.ThenBranch)
+
+	elseJump := c.emitJump(OP_JUMP)
+	c.patchJump(thenJump)
+	c.emitOp(OP_POP)
+	if s.ElseBranch != nil {
+		c.compileStmt(s.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil
+}
+
+func (c *Compiler) VisitPrintStmt(p *parser.Print) any {
+	c.compileExpr(p.Expression)
+	c.emitOp(OP_PRINT)
+	return nil
+}
+
+func (c *Compiler) VisitReturnStmt(r *parser.Return) any {
+	c.token = r.Keyword
+	if r.Value == nil && len(c.current.tries) == 0 {
+		c.emitReturn()
+		return nil
+	}
+	if r.Value == nil {
+		c.emitReturnValue()
+	} else {
+		c.compileExpr(r.Value)
+	}
+
+	if tries := c.current.tries; len(tries) > 0 {
+		// keep the value in a hidden local while the finally clauses run
+		c.beginScope()
+		c.addLocal("")
+		value := uint8(len(c.current.locals) - 1)
+		for idx := len(tries) - 1; idx >= 0; idx-- {
+			c.current.tries = tries[:idx]
+			c.token = r.Keyword
+			c.emitOp(OP_END_TRY)
+			c.compileFinally(tries[idx].finally)
+		}
+		c.current.tries = tries
+		c.token = r.Keyword
+		c.emitOp(OP_GET_LOCAL)
+		c.emitByte(value)
+		c.emitOp(OP_RETURN)
+		c.endScope()
+		return nil
+	}
+	c.emitOp(OP_RETURN)
+	return nil
+}
+
+func (c *Compiler) VisitThrowStmt(t *parser.Throw) any {
+	c.compileExpr(t.Value)
+	c.token = t.Keyword
+	c.emitOp(OP_THROW)
+	return nil
+}
+
+// VisitTryStmt compiles the try block under a handler. When a value is
+// thrown the VM unwinds to the handler with the value on top of the stack,
+// where the catch clause binds it. The finally clause is compiled into every
+// way out of the statement.
+func (c *Compiler) VisitTryStmt(t *parser.Try) any {
+	c.token = t.Keyword
+	handler := c.emitJump(OP_TRY)
+	c.protected(t.Body, t.Finally)
+	c.token = t.Keyword
+	c.emitOp(OP_END_TRY)
+	c.compileFinally(t.Finally)
+	ends := []int{c.emitJump(OP_JUMP)}
+
+	c.patchJump(handler)
+	if t.Catch == nil {
+		c.rethrowAfter(t.Finally, 1)
+	} else {
+		c.beginScope()
+		c.token = t.Name
+		c.addLocal(t.Name.Lexeme)
+		var rethrow int
+		if t.Finally != nil {
+			rethrow = c.emitJump(OP_TRY)
+			c.protected(t.Catch, t.Finally)
+			c.token = t.Keyword
+			c.emitOp(OP_END_TRY)
+		} else {
+			c.compileStmts(t.Catch)
+		}
+		c.endScope()
+		c.compileFinally(t.Finally)
+
+		if t.Finally != nil {
+			ends = append(ends, c.emitJump(OP_JUMP))
+			c.patchJump(rethrow)
+			// the caught value is still below the new one
+			c.rethrowAfter(t.Finally, 2)
+		}
+	}
+	for _, end := range ends {
+		c.patchJump(end)
+	}
+	return nil
+}
+
+// protected compiles statements in their own scope while a handler whose
+// try statement has the finally clause is active.
+func (c *Compiler) protected(statements, finally []parser.Stmt) {
+	c.current.tries = append(c.current.tries, tryState{finally: finally})
+	c.beginScope()
+	c.compileStmts(statements)
+	c.endScope()
+	c.current.tries = c.current.tries[:len(c.current.tries)-1]
+}
+
+// rethrowAfter runs finally for a value thrown in a try statement, then
+// throws the value on. hidden is the number of values the handler left on
+// the stack, the thrown value last.
+func (c *Compiler) rethrowAfter(finally []parser.Stmt, hidden int) {
+	c.beginScope()
+	for idx := 0; idx < hidden; idx++ {
+		c.addLocal("")
+	}
+	thrown := uint8(len(c.current.locals) - 1)
+	c.compileFinally(finally)
+	c.emitOp(OP_GET_LOCAL)
+	c.emitByte(thrown)
+	c.emitOp(OP_THROW)
+	c.endScope()
+}
+
+func (c *Compiler) compileFinally(finally []parser.Stmt) {
+	if finally == nil {
+		return
+	}
+	c.beginScope()
+	c.compileStmts(finally)
+	c.endScope()
+}
+
+func (c *Compiler) VisitVarStmt(v *parser.Var) any {
+	c.token = v.Name
+	var global uint16
+	if c.current.scopeDepth == 0 {
+		global = c.identifierConstant(v.Name.Lexeme)
+	}
+	if v.Initializer != nil {
+		c.compileExpr(v.Initializer)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+	// the initialized value already sits in the new local's stack slot
+	c.declareVariable(v.Name.Lexeme)
+	c.defineVariable(global)
+	return nil
+}
+
+func (c *Compiler) VisitWhileStmt(w *parser.While) any {
+	loopStart := len(c.currentChunk().Code)
+	c.compileExpr(w.Condition)
+
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileStmt(w.Body)
+	c.emitLoop(loopStart)
+
+	c.patchJump(exitJump)
+	c.emitOp(OP_POP)
+	return nil
+}
+
+func (c *Compiler) VisitAssignExpr(a *parser.Assign) any {
+	c.compileExpr(a.Value)
+	c.namedVariable(a.Name, true)
+	return nil
+}
+
+func (c *Compiler) VisitBinaryExpr(b *parser.Binary) any {
+	c.compileExpr(b.Left)
+	c.compileExpr(b.Right)
+
+	c.token = b.Operator
+	switch b.Operator.Type {
+	case scanner.BANG_EQUAL:
+		c.emitOp(OP_EQUAL)
+		c.emitOp(OP_NOT)
+	case scanner.EQUAL_EQUAL:
+		c.emitOp(OP_EQUAL)
+	case scanner.GREATER:
+		c.emitOp(OP_GREATER)
+	case scanner.GREATER_EQUAL:
+		c.emitOp(OP_GREATER_EQUAL)
+	case scanner.LESS:
+		c.emitOp(OP_LESS)
+	case scanner.LESS_EQUAL:
+		c.emitOp(OP_LESS_EQUAL)
+	case scanner.PLUS:
+		c.emitOp(OP_ADD)
+	case scanner.MINUS:
+		c.emitOp(OP_SUBTRACT)
+	case scanner.STAR:
+		c.emitOp(OP_MULTIPLY)
+	case scanner.SLASH:
+		c.emitOp(OP_DIVIDE)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitCallExpr(call *parser.Call) any {
+	c.compileExpr(call.Callee)
+	for _, argument := range call.Arguments {
+		c.compileExpr(argument)
+	}
+	c.token = call.Paren
+	c.emitOp(OP_CALL)
+	c.emitByte(uint8(len(call.Arguments)))
+	return nil
+}
+
+func (c *Compiler) VisitGetExpr(g *parser.Get) any {
+	c.compileExpr(g.Object)
+	c.token = g.Name
+	c.emitOpShort(OP_GET_PROPERTY, c.identifierConstant(g.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitListExpr(l *parser.List) any {
+	for _, element := range l.Elements {
+		c.compileExpr(element)
+	}
+	c.token = l.Bracket
+	c.emitOp(OP_BUILD_LIST)
+	c.emitByte(uint8(len(l.Elements)))
+	return nil
+}
+
+func (c *Compiler) VisitMapExpr(m *parser.Map) any {
+	for idx := range m.Keys {
+		c.compileExpr(m.Keys[idx])
+		c.compileExpr(m.Values[idx])
+	}
+	c.token = m.Brace
+	c.emitOp(OP_BUILD_MAP)
+	c.emitByte(uint8(len(m.Keys)))
+	return nil
+}
+
+func (c *Compiler) VisitIndexExpr(i *parser.Index) any {
+	c.compileExpr(i.Object)
+	c.compileExpr(i.Index)
+	c.token = i.Bracket
+	c.emitOp(OP_GET_INDEX)
+	return nil
+}
+
+func (c *Compiler) VisitSetIndexExpr(s *parser.SetIndex) any {
+	c.compileExpr(s.Object)
+	c.compileExpr(s.Index)
+	c.compileExpr(s.Value)
+	c.token = s.Bracket
+	c.emitOp(OP_SET_INDEX)
+	return nil
+}
+
+func (c *Compiler) VisitGroupingExpr(g *parser.Grouping) any {
+	c.compileExpr(g.Expression)
+	return nil
+}
+
+func (c *Compiler) VisitLiteralExpr(l *parser.Literal) any {
+	switch v := l.Value.(type) {
+	case nil:
+		c.emitOp(OP_NIL)
+	case bool:
+		if v {
+			c.emitOp(OP_TRUE)
+		} else {
+			c.emitOp(OP_FALSE)
+		}
+	case float64:
+		c.emitOpShort(OP_CONSTANT, c.makeConstant(NumberValue(v)))
+	case string:
+		c.emitOpShort(OP_CONSTANT, c.makeConstant(ObjValue(v)))
+	}
+	return nil
+}
+
+func (c *Compiler) VisitLogicalExpr(l *parser.Logical) any {
+	c.compileExpr(l.Left)
+	c.token = l.Operator
+	if l.Operator.Type == scanner.OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(elseJump)
+		c.emitOp(OP_POP)
+		c.compileExpr(l.Right)
+		c.patchJump(endJump)
+		return nil
+	}
+	endJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileExpr(l.Right)
+	c.patchJump(endJump)
+	return nil
+}
+
+func (c *Compiler) VisitSetExpr(s *parser.Set) any {
+	c.compileExpr(s.Object)
+	c.compileExpr(s.Value)
+	c.token = s.Name
+	c.emitOpShort(OP_SET_PROPERTY, c.identifierConstant(s.Name.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitSuperExpr(s *parser.Super) any {
+	this := s.Keyword
+	this.Lexeme = "this"
+	c.namedVariable(this, false)
+	c.namedVariable(s.Keyword, false)
+	c.token = s.Method
+	c.emitOpShort(OP_GET_SUPER, c.identifierConstant(s.Method.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitThisExpr(t *parser.This) any {
+	c.namedVariable(t.Keyword, false)
+	return nil
+}
+
+func (c *Compiler) VisitUnaryExpr(u *parser.Unary) any {
+	c.compileExpr(u.Right)
+	c.token = u.Operator
+	switch u.Operator.Type {
+	case scanner.BANG:
+		c.emitOp(OP_NOT)
+	case scanner.MINUS:
+		c.emitOp(OP_NEGATE)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitVariableExpr(v *parser.Variable) any {
+	c.namedVariable(v.Name, false)
+	return nil
+}
+
+func (c *Compiler) compileStmts(statements []parser.Stmt) {
+	for _, statement := range statements {
+		c.compileStmt(statement)
+	}
+}
+
+func (c *Compiler) compileStmt(stmt parser.Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Compiler) compileExpr(expr parser.Expr) {
+	expr.Accept(c)
+}
+
+func (c *Compiler) namedVariable(name scanner.Token, assign bool) {
+	c.token = name
+	if arg := c.resolveLocal(c.current, name.Lexeme); arg != -1 {
+		c.emitOp(lo.Ternary(assign, OP_SET_LOCAL, OP_GET_LOCAL))
+		c.emitByte(uint8(arg))
+	} else if arg := c.resolveUpvalue(c.current, name.Lexeme); arg != -1 {
+		c.emitOp(lo.Ternary(assign, OP_SET_UPVALUE, OP_GET_UPVALUE))
+		c.emitByte(uint8(arg))
+	} else {
+		c.emitOpShort(lo.Ternary(assign, OP_SET_GLOBAL, OP_GET_GLOBAL), c.identifierConstant(name.Lexeme))
+	}
+}
+
+func (c *Compiler) resolveLocal(state *funcState, name string) int {
+	for idx := len(state.locals) - 1; idx >= 0; idx-- {
+		if state.locals[idx].name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(state *funcState, name string) int {
+	if state.enclosing == nil {
+		return -1
+	}
+	if local := c.resolveLocal(state.enclosing, name); local != -1 {
+		state.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(state, uint8(local), true)
+	}
+	if upvalue := c.resolveUpvalue(state.enclosing, name); upvalue != -1 {
+		return c.addUpvalue(state, uint8(upvalue), false)
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(state *funcState, index uint8, isLocal bool) int {
+	for idx, uv := range state.upvalues {
+		if uv.index == index && uv.isLocal == isLocal {
+			return idx
+		}
+	}
+	if len(state.upvalues) == maxLocals {
+		c.Error("Too many closure variables in function.")
+	}
+	state.upvalues = append(state.upvalues, upvalue{index: index, isLocal: isLocal})
+	return len(state.upvalues) - 1
+}
+
+func (c *Compiler) addLocal(name string) {
+	if len(c.current.locals) == maxLocals {
+		c.Error("Too many local variables in function.")
+	}
+	c.current.locals = append(c.current.locals, local{name: name, depth: c.current.scopeDepth})
+}
+
+func (c *Compiler) declareVariable(name string) {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+	c.addLocal(name)
+}
+
+func (c *Compiler) defineVariable(global uint16) {
+	if c.current.scopeDepth > 0 {
+		return
+	}
+	c.emitOpShort(OP_DEFINE_GLOBAL, global)
+}
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	state := c.current
+	state.scopeDepth--
+	for len(state.locals) > 0 && state.locals[len(state.locals)-1].depth > state.scopeDepth {
+		if state.locals[len(state.locals)-1].isCaptured {
+			c.emitOp(OP_CLOSE_UPVALUE)
+		} else {
+			c.emitOp(OP_POP)
+		}
+		state.locals = state.locals[:len(state.locals)-1]
+	}
+}
+
+func (c *Compiler) currentChunk() *Chunk {
+	return &c.current.function.Chunk
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.currentChunk().Write(b, c.token)
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitOpShort(op OpCode, operand uint16) {
+	c.emitOp(op)
+	c.emitByte(byte(operand >> 8))
+	c.emitByte(byte(operand))
+}
+
+func (c *Compiler) emitReturn() {
+	c.emitReturnValue()
+	c.emitOp(OP_RETURN)
+}
+
+// emitReturnValue pushes what a bare return returns.
+func (c *Compiler) emitReturnValue() {
+	if c.current.funcType == TYPE_INITIALIZER {
+		c.emitOp(OP_GET_LOCAL)
+		c.emitByte(0)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+}
+
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitOpShort(op, math.MaxUint16)
+	return len(c.currentChunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	code := c.currentChunk().Code
+	jump := len(code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.Error("Too much code to jump over.")
+	}
+	code[offset] = byte(jump >> 8)
+	code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	offset := len(c.currentChunk().Code) - loopStart + 3
+	if offset > math.MaxUint16 {
+		c.Error("Loop body too large.")
+	}
+	c.emitOpShort(OP_LOOP, uint16(offset))
+}
+
+func (c *Compiler) makeConstant(value Value) uint16 {
+	constant := c.currentChunk().AddConstant(value)
+	if constant > math.MaxUint16 {
+		c.Error("Too many constants in one chunk.")
+	}
+	return uint16(constant)
+}
+
+func (c *Compiler) identifierConstant(name string) uint16 {
+	return c.makeConstant(ObjValue(name))
+}
+
+func (c *Compiler) Error(message string) {
+	panic(&parser.ParseError{Token: c.token, Message: message})
+}
@@ -0,0 +1,80 @@
+package vm
+
+import "fmt"
+
+type Function struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+	// File is the script the function was compiled from, for stack traces.
+	File string
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
+
+type Closure struct {
+	Function *Function
+	Upvalues []*Upvalue
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+func (c *Closure) TypeName() string {
+	return "function"
+}
+
+// Upvalue points at a stack slot while the captured variable is still on the
+// stack, and at its own closed field once the variable goes out of scope.
+type Upvalue struct {
+	location *Value
+	closed   Value
+	slot     int
+	next     *Upvalue
+}
+
+type Class struct {
+	Name    string
+	Methods map[string]*Closure
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+func (c *Class) TypeName() string {
+	return "class"
+}
+
+type Instance struct {
+	Class  *Class
+	Fields map[string]Value
+}
+
+func (i *Instance) String() string {
+	return i.Class.Name + " instance"
+}
+
+func (i *Instance) TypeName() string {
+	return "instance"
+}
+
+type BoundMethod struct {
+	Receiver Value
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+func (b *BoundMethod) TypeName() string {
+	return "function"
+}
@@ -0,0 +1,124 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type ValueType uint8
+
+const (
+	VAL_NIL ValueType = iota
+	VAL_BOOL
+	VAL_NUMBER
+	VAL_OBJ
+)
+
+// Value is an unboxed Lox value. Booleans and numbers live in num so that
+// arithmetic never allocates; strings and heap objects live in obj.
+type Value struct {
+	Type ValueType
+	num  float64
+	obj  any
+}
+
+func NilValue() Value {
+	return Value{Type: VAL_NIL}
+}
+
+func BoolValue(b bool) Value {
+	if b {
+		return Value{Type: VAL_BOOL, num: 1}
+	}
+	return Value{Type: VAL_BOOL}
+}
+
+func NumberValue(n float64) Value {
+	return Value{Type: VAL_NUMBER, num: n}
+}
+
+func ObjValue(o any) Value {
+	return Value{Type: VAL_OBJ, obj: o}
+}
+
+// FromAny converts a Go value into a Lox value. Go numbers become Lox
+// numbers; values of other types are stored as opaque objects.
+func FromAny(v any) Value {
+	switch v := v.(type) {
+	case nil:
+		return NilValue()
+	case bool:
+		return BoolValue(v)
+	case float64:
+		return NumberValue(v)
+	case Value:
+		return v
+	}
+	return ObjValue(v)
+}
+
+// Any converts the value into nil, bool, float64, string or the object
+// itself, the same representation the tree-walking interpreter uses.
+func (v Value) Any() any {
+	switch v.Type {
+	case VAL_NIL:
+		return nil
+	case VAL_BOOL:
+		return v.AsBool()
+	case VAL_NUMBER:
+		return v.num
+	}
+	return v.obj
+}
+
+func (v Value) IsNumber() bool {
+	return v.Type == VAL_NUMBER
+}
+
+func (v Value) IsString() bool {
+	_, ok := v.obj.(string)
+	return v.Type == VAL_OBJ && ok
+}
+
+func (v Value) AsNumber() float64 {
+	return v.num
+}
+
+func (v Value) AsBool() bool {
+	return v.num != 0
+}
+
+func (v Value) AsString() string {
+	return v.obj.(string)
+}
+
+func (v Value) isFalsey() bool {
+	return v.Type == VAL_NIL || (v.Type == VAL_BOOL && !v.AsBool())
+}
+
+// String formats the value the same way the tree-walking interpreter prints
+// its Go representation.
+func (v Value) String() string {
+	switch v.Type {
+	case VAL_NIL:
+		return fmt.Sprint(nil)
+	case VAL_BOOL:
+		return strconv.FormatBool(v.AsBool())
+	case VAL_NUMBER:
+		return fmt.Sprint(v.num)
+	}
+	return fmt.Sprint(v.obj)
+}
+
+func valuesEqual(a, b Value) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case VAL_NIL:
+		return true
+	case VAL_BOOL, VAL_NUMBER:
+		return a.num == b.num
+	}
+	return a.obj == b.obj
+}
@@ -0,0 +1,671 @@
+package vm
+
+import (
+	"craftinginterpreters/lox/interpreter"
+	"craftinginterpreters/lox/parser"
+	"craftinginterpreters/lox/scanner"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// framesInitial and stackInitial are the sizes the call and value
+	// stacks start at; both grow as deeper calls need them.
+	framesInitial = 64
+	stackInitial  = framesInitial * 4
+)
+
+type CallFrame struct {
+	closure *Closure
+	ip      int
+	slots   int
+}
+
+type VM struct {
+	frames       []CallFrame
+	frameCount   int
+	stack        []Value
+	stackTop     int
+	globals      map[string]Value
+	openUpvalues *Upvalue
+	stdout       io.Writer
+	// handlers are the try statements being executed, innermost last.
+	handlers []handler
+	// sourceName is the file the following scripts come from.
+	sourceName string
+	// maxCallDepth is the number of nested Lox function calls allowed.
+	maxCallDepth int
+}
+
+// handler is where execution resumes when a value is thrown inside a try
+// statement.
+type handler struct {
+	frameCount int
+	stackTop   int
+	ip         int
+}
+
+// New returns a VM with every built-in module installed and io working on
+// the host's stdin and files, like interpreter.NewInterpreter.
+func New() *VM {
+	vm := &VM{
+		frames:       make([]CallFrame, framesInitial),
+		stack:        make([]Value, stackInitial),
+		globals:      map[string]Value{},
+		stdout:       os.Stdout,
+		maxCallDepth: interpreter.DefaultMaxCallDepth,
+	}
+	for _, module := range interpreter.Modules() {
+		vm.InstallModule(module)
+	}
+	vm.InstallIO(interpreter.IO{Stdin: os.Stdin, FS: interpreter.OSFileSystem{}})
+	return vm
+}
+
+// stdoutWriter writes wherever the VM's print statements go, even after
+// SetStdout redirects them.
+type stdoutWriter struct {
+	vm *VM
+}
+
+func (w stdoutWriter) Write(p []byte) (int, error) {
+	return w.vm.stdout.Write(p)
+}
+
+// InstallIO installs the io module working on config, whose nil Stdout
+// means the VM's own stdout.
+func (vm *VM) InstallIO(config interpreter.IO) {
+	if config.Stdout == nil {
+		config.Stdout = stdoutWriter{vm}
+	}
+	vm.InstallModule(interpreter.IOModule(config))
+}
+
+// SetMaxCallDepth sets the number of nested Lox function calls a script may
+// make, with the meaning of interpreter.Limits.MaxCallDepth.
+func (vm *VM) SetMaxCallDepth(depth int) {
+	if depth <= 0 {
+		depth = interpreter.DefaultMaxCallDepth
+	}
+	vm.maxCallDepth = depth
+}
+
+// SetStdout redirects the output of print statements.
+func (vm *VM) SetStdout(w io.Writer) {
+	vm.stdout = w
+}
+
+// DefineGlobal binds name as a global variable, replacing any existing one.
+func (vm *VM) DefineGlobal(name string, value Value) {
+	vm.globals[name] = value
+}
+
+// DefineNative binds name to a Go function that takes Lox values directly.
+// An arity of -1 accepts any number of arguments.
+func (vm *VM) DefineNative(name string, arity int, fn func(arguments []any) (any, error)) {
+	vm.globals[name] = ObjValue(interpreter.NewNativeFunction(name, arity, fn))
+}
+
+// InstallModule defines the functions of module as globals.
+func (vm *VM) InstallModule(module *interpreter.Module) {
+	for _, function := range module.Functions {
+		vm.globals[function.Name] = ObjValue(function)
+	}
+}
+
+// RegisterFunc binds name to an ordinary Go function, converting arguments
+// and results as described by interpreter.WrapFunc.
+func (vm *VM) RegisterFunc(name string, fn any) error {
+	native, err := interpreter.WrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	vm.globals[name] = ObjValue(native)
+	return nil
+}
+
+// Global returns the value of the global variable name.
+func (vm *VM) Global(name string) (Value, bool) {
+	value, ok := vm.globals[name]
+	return value, ok
+}
+
+// Globals returns a copy of the global variables.
+func (vm *VM) Globals() map[string]Value {
+	res := make(map[string]Value, len(vm.globals))
+	for name, value := range vm.globals {
+		res[name] = value
+	}
+	return res
+}
+
+// SetSourceName names the file the following scripts come from, for stack
+// traces. The default "" means they were not read from a file.
+func (vm *VM) SetSourceName(name string) {
+	vm.sourceName = name
+}
+
+// Interpret compiles statements to bytecode and runs them. Globals survive
+// between calls so the VM can back a REPL.
+func (vm *VM) Interpret(statements []parser.Stmt) error {
+	function, err := CompileFile(vm.sourceName, statements)
+	if err != nil {
+		return err
+	}
+	_, err = vm.execute(function)
+	return err
+}
+
+// Eval is like Interpret but returns the value of the last statement if it
+// is an expression statement.
+func (vm *VM) Eval(statements []parser.Stmt) (Value, error) {
+	function, err := compile(vm.sourceName, statements, true)
+	if err != nil {
+		return NilValue(), err
+	}
+	return vm.execute(function)
+}
+
+// Evaluate compiles and runs a single expression, returning its value.
+func (vm *VM) Evaluate(expr parser.Expr) (Value, error) {
+	function, err := CompileExpression(expr)
+	if err != nil {
+		return NilValue(), err
+	}
+	function.File = vm.sourceName
+	return vm.execute(function)
+}
+
+func (vm *VM) execute(function *Function) (result Value, err error) {
+	defer func() {
+		if terr := recover(); terr != nil {
+			vm.resetStack()
+			result = NilValue()
+			err = fmt.Errorf("internal vm error: %v", terr)
+		}
+	}()
+	closure := &Closure{Function: function}
+	vm.push(ObjValue(closure))
+	if err := vm.call(closure, 0); err != nil {
+		vm.resetStack()
+		return NilValue(), err
+	}
+	return vm.run()
+}
+
+// run executes until the script returns or raises an error that no try
+// statement catches.
+func (vm *VM) run() (Value, error) {
+	for {
+		value, err := vm.loop()
+		if err == nil {
+			return value, nil
+		}
+		if !vm.catch(err) {
+			vm.annotate(err)
+			vm.resetStack()
+			return NilValue(), err
+		}
+	}
+}
+
+// catch unwinds to the innermost handler and hands it the value for err.
+func (vm *VM) catch(err error) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	if vm.frameCount > h.frameCount {
+		// the frames the error was raised in are about to go; a rethrow
+		// should still report them, as it does in the interpreter
+		vm.annotate(err)
+	}
+	value, ok := interpreter.Caught(err)
+	if !ok {
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.closeUpvalues(h.stackTop)
+	vm.frameCount = h.frameCount
+	vm.stackTop = h.stackTop
+	vm.push(FromAny(value))
+	vm.frames[vm.frameCount-1].ip = h.ip
+	return true
+}
+
+func (vm *VM) loop() (Value, error) {
+	frame := &vm.frames[vm.frameCount-1]
+	chunk := &frame.closure.Function.Chunk
+
+	readByte := func() byte {
+		frame.ip++
+		return chunk.Code[frame.ip-1]
+	}
+	readShort := func() uint16 {
+		frame.ip += 2
+		return uint16(chunk.Code[frame.ip-2])<<8 | uint16(chunk.Code[frame.ip-1])
+	}
+	readString := func() string {
+		return chunk.Constants[readShort()].AsString()
+	}
+
+	for {
+		switch OpCode(readByte()) {
+		case OP_CONSTANT:
+			vm.push(chunk.Constants[readShort()])
+		case OP_NIL:
+			vm.push(NilValue())
+		case OP_TRUE:
+			vm.push(BoolValue(true))
+		case OP_FALSE:
+			vm.push(BoolValue(false))
+		case OP_POP:
+			vm.pop()
+		case OP_GET_LOCAL:
+			vm.push(vm.stack[frame.slots+int(readByte())])
+		case OP_SET_LOCAL:
+			vm.stack[frame.slots+int(readByte())] = vm.peek(0)
+		case OP_GET_GLOBAL:
+			name := readString()
+			value, ok := vm.globals[name]
+			if !ok {
+				return NilValue(), vm.runtimeError("Undefined variable '%s'.", name)
+			}
+			vm.push(value)
+		case OP_DEFINE_GLOBAL:
+			vm.globals[readString()] = vm.peek(0)
+			vm.pop()
+		case OP_SET_GLOBAL:
+			name := readString()
+			if _, ok := vm.globals[name]; !ok {
+				return NilValue(), vm.runtimeError("Undefined variable '%s'.", name)
+			}
+			vm.globals[name] = vm.peek(0)
+		case OP_GET_UPVALUE:
+			vm.push(*frame.closure.Upvalues[readByte()].location)
+		case OP_SET_UPVALUE:
+			*frame.closure.Upvalues[readByte()].location = vm.peek(0)
+		case OP_GET_PROPERTY:
+			name := readString()
+			if lerr, ok := vm.peek(0).obj.(*interpreter.LoxError); ok {
+				value, ok := lerr.Get(name)
+				if !ok {
+					return NilValue(), vm.runtimeError("Undefined property '%s'.", name)
+				}
+				vm.pop()
+				vm.push(FromAny(value))
+				break
+			}
+			instance, ok := vm.peek(0).obj.(*Instance)
+			if !ok {
+				return NilValue(), vm.runtimeError("Only instances have properties.")
+			}
+			if value, ok := instance.Fields[name]; ok {
+				vm.pop()
+				vm.push(value)
+				break
+			}
+			if err := vm.bindMethod(instance.Class, name); err != nil {
+				return NilValue(), err
+			}
+		case OP_BUILD_LIST:
+			count := int(readByte())
+			elements := make([]any, count)
+			for idx, element := range vm.stack[vm.stackTop-count : vm.stackTop] {
+				elements[idx] = element.Any()
+			}
+			vm.stackTop -= count
+			vm.push(ObjValue(interpreter.NewLoxList(elements)))
+		case OP_BUILD_MAP:
+			count := int(readByte())
+			m := interpreter.NewLoxMap()
+			entries := vm.stack[vm.stackTop-2*count : vm.stackTop]
+			for idx := 0; idx < len(entries); idx += 2 {
+				if err := m.Set(entries[idx].Any(), entries[idx+1].Any()); err != nil {
+					return NilValue(), vm.runtimeError("%s", err)
+				}
+			}
+			vm.stackTop -= 2 * count
+			vm.push(ObjValue(m))
+		case OP_GET_INDEX:
+			value, err := interpreter.GetIndex(vm.peek(1).Any(), vm.peek(0).Any())
+			if err != nil {
+				return NilValue(), vm.runtimeError("%s", err)
+			}
+			vm.stackTop -= 2
+			vm.push(FromAny(value))
+		case OP_SET_INDEX:
+			value := vm.peek(0)
+			if err := interpreter.SetIndex(vm.peek(2).Any(), vm.peek(1).Any(), value.Any()); err != nil {
+				return NilValue(), vm.runtimeError("%s", err)
+			}
+			vm.stackTop -= 3
+			vm.push(value)
+		case OP_SET_PROPERTY:
+			name := readString()
+			instance, ok := vm.peek(1).obj.(*Instance)
+			if !ok {
+				return NilValue(), vm.runtimeError("Only instances have fields.")
+			}
+			instance.Fields[name] = vm.peek(0)
+			value := vm.pop()
+			vm.pop()
+			vm.push(value)
+		case OP_GET_SUPER:
+			name := readString()
+			superclass := vm.pop().obj.(*Class)
+			if err := vm.bindMethod(superclass, name); err != nil {
+				return NilValue(), err
+			}
+		case OP_EQUAL:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(BoolValue(valuesEqual(a, b)))
+		case OP_GREATER, OP_GREATER_EQUAL, OP_LESS, OP_LESS_EQUAL,
+			OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE:
+			op := OpCode(chunk.Code[frame.ip-1])
+			if !vm.peek(0).IsNumber() || !vm.peek(1).IsNumber() {
+				return NilValue(), vm.runtimeError("Operand must be a number.")
+			}
+			b := vm.pop().AsNumber()
+			a := vm.pop().AsNumber()
+			vm.push(binaryNumberOp(op, a, b))
+		case OP_ADD:
+			if vm.peek(0).IsNumber() && vm.peek(1).IsNumber() {
+				b := vm.pop().AsNumber()
+				a := vm.pop().AsNumber()
+				vm.push(NumberValue(a + b))
+			} else if vm.peek(0).IsString() && vm.peek(1).IsString() {
+				b := vm.pop().AsString()
+				a := vm.pop().AsString()
+				vm.push(ObjValue(a + b))
+			} else {
+				return NilValue(), vm.runtimeError("Operands must be two number or strings.")
+			}
+		case OP_NOT:
+			vm.push(BoolValue(vm.pop().isFalsey()))
+		case OP_NEGATE:
+			if !vm.peek(0).IsNumber() {
+				return NilValue(), vm.runtimeError("Operand must be a number.")
+			}
+			vm.push(NumberValue(-vm.pop().AsNumber()))
+		case OP_PRINT:
+			fmt.Fprintln(vm.stdout, vm.pop())
+		case OP_JUMP:
+			offset := readShort()
+			frame.ip += int(offset)
+		case OP_JUMP_IF_FALSE:
+			offset := readShort()
+			if vm.peek(0).isFalsey() {
+				frame.ip += int(offset)
+			}
+		case OP_LOOP:
+			offset := readShort()
+			frame.ip -= int(offset)
+		case OP_CALL:
+			argCount := int(readByte())
+			if err := vm.callValue(vm.peek(argCount), argCount); err != nil {
+				return NilValue(), err
+			}
+			frame = &vm.frames[vm.frameCount-1]
+			chunk = &frame.closure.Function.Chunk
+		case OP_CLOSURE:
+			function := chunk.Constants[readShort()].obj.(*Function)
+			closure := &Closure{
+				Function: function,
+				Upvalues: make([]*Upvalue, function.UpvalueCount),
+			}
+			vm.push(ObjValue(closure))
+			for idx := range closure.Upvalues {
+				isLocal := readByte()
+				index := int(readByte())
+				if isLocal == 1 {
+					closure.Upvalues[idx] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.Upvalues[idx] = frame.closure.Upvalues[index]
+				}
+			}
+		case OP_CLOSE_UPVALUE:
+			vm.closeUpvalues(vm.stackTop - 1)
+			vm.pop()
+		case OP_RETURN:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.frameCount--
+			if vm.frameCount == 0 {
+				vm.pop()
+				return result, nil
+			}
+			vm.stackTop = frame.slots
+			vm.push(result)
+			frame = &vm.frames[vm.frameCount-1]
+			chunk = &frame.closure.Function.Chunk
+		case OP_TRY:
+			offset := readShort()
+			vm.handlers = append(vm.handlers, handler{
+				frameCount: vm.frameCount,
+				stackTop:   vm.stackTop,
+				ip:         frame.ip + int(offset),
+			})
+		case OP_END_TRY:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OP_THROW:
+			err := interpreter.NewThrowError(vm.token(), vm.pop().Any())
+			if err.Stack == nil {
+				err.Stack = vm.stackTrace()
+			}
+			return NilValue(), err
+		case OP_CLASS:
+			vm.push(ObjValue(&Class{Name: readString(), Methods: map[string]*Closure{}}))
+		case OP_INHERIT:
+			superclass, ok := vm.peek(1).obj.(*Class)
+			if !ok {
+				return NilValue(), vm.runtimeError("Superclass must be a class.")
+			}
+			subclass := vm.peek(0).obj.(*Class)
+			for name, method := range superclass.Methods {
+				subclass.Methods[name] = method
+			}
+			vm.pop()
+		case OP_METHOD:
+			name := readString()
+			method := vm.peek(0).obj.(*Closure)
+			class := vm.peek(1).obj.(*Class)
+			class.Methods[name] = method
+			vm.pop()
+		}
+	}
+}
+
+func binaryNumberOp(op OpCode, a, b float64) Value {
+	switch op {
+	case OP_GREATER:
+		return BoolValue(a > b)
+	case OP_GREATER_EQUAL:
+		return BoolValue(a >= b)
+	case OP_LESS:
+		return BoolValue(a < b)
+	case OP_LESS_EQUAL:
+		return BoolValue(a <= b)
+	case OP_SUBTRACT:
+		return NumberValue(a - b)
+	case OP_MULTIPLY:
+		return NumberValue(a * b)
+	}
+	return NumberValue(a / b)
+}
+
+func (vm *VM) callValue(callee Value, argCount int) error {
+	switch c := callee.obj.(type) {
+	case *BoundMethod:
+		vm.stack[vm.stackTop-argCount-1] = c.Receiver
+		return vm.call(c.Method, argCount)
+	case *Class:
+		vm.stack[vm.stackTop-argCount-1] = ObjValue(&Instance{Class: c, Fields: map[string]Value{}})
+		if initializer, ok := c.Methods["init"]; ok {
+			return vm.call(initializer, argCount)
+		}
+		if argCount != 0 {
+			return vm.runtimeError("Expected 0 arguments but got %d.", argCount)
+		}
+		return nil
+	case *Closure:
+		return vm.call(c, argCount)
+	case *interpreter.NativeFunction:
+		return vm.callNative(c, argCount)
+	}
+	return vm.runtimeError("Can only call functions and classes.")
+}
+
+func (vm *VM) call(closure *Closure, argCount int) error {
+	if argCount != closure.Function.Arity {
+		return vm.runtimeError("Expected %d arguments but got %d.", closure.Function.Arity, argCount)
+	}
+	// the script itself takes the first frame
+	if vm.frameCount > vm.maxCallDepth {
+		return interpreter.NewLimitError(vm.token(), interpreter.ErrCallDepth)
+	}
+	if vm.frameCount == len(vm.frames) {
+		vm.frames = append(vm.frames, make([]CallFrame, len(vm.frames))...)
+	}
+	vm.frames[vm.frameCount] = CallFrame{
+		closure: closure,
+		slots:   vm.stackTop - argCount - 1,
+	}
+	vm.frameCount++
+	return nil
+}
+
+// callNative runs a Go function on the arguments on top of the stack and
+// replaces them and the callee with its result.
+func (vm *VM) callNative(native *interpreter.NativeFunction, argCount int) error {
+	if native.Arity() >= 0 && argCount != native.Arity() {
+		return vm.runtimeError("Expected %d arguments but got %d.", native.Arity(), argCount)
+	}
+	arguments := make([]any, argCount)
+	for idx, argument := range vm.stack[vm.stackTop-argCount : vm.stackTop] {
+		arguments[idx] = argument.Any()
+	}
+	result, err := native.Invoke(arguments)
+	if err != nil {
+		return interpreter.NativeError(vm.token(), err)
+	}
+	vm.stackTop -= argCount + 1
+	vm.push(FromAny(result))
+	return nil
+}
+
+// bindMethod replaces the receiver on top of the stack with its method name
+// looked up on class.
+func (vm *VM) bindMethod(class *Class, name string) error {
+	method, ok := class.Methods[name]
+	if !ok {
+		return vm.runtimeError("Undefined property '%s'.", name)
+	}
+	bound := &BoundMethod{Receiver: vm.peek(0), Method: method}
+	vm.pop()
+	vm.push(ObjValue(bound))
+	return nil
+}
+
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	var prev *Upvalue
+	upvalue := vm.openUpvalues
+	for upvalue != nil && upvalue.slot > slot {
+		prev = upvalue
+		upvalue = upvalue.next
+	}
+	if upvalue != nil && upvalue.slot == slot {
+		return upvalue
+	}
+
+	created := &Upvalue{location: &vm.stack[slot], slot: slot, next: upvalue}
+	if prev == nil {
+		vm.openUpvalues = created
+	} else {
+		prev.next = created
+	}
+	return created
+}
+
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = *upvalue.location
+		upvalue.location = &upvalue.closed
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+func (vm *VM) push(value Value) {
+	if vm.stackTop == len(vm.stack) {
+		vm.growStack()
+	}
+	vm.stack[vm.stackTop] = value
+	vm.stackTop++
+}
+
+// growStack doubles the value stack, moving the open upvalues that point
+// into it.
+func (vm *VM) growStack() {
+	vm.stack = append(vm.stack, make([]Value, len(vm.stack))...)
+	for upvalue := vm.openUpvalues; upvalue != nil; upvalue = upvalue.next {
+		upvalue.location = &vm.stack[upvalue.slot]
+	}
+}
+
+func (vm *VM) pop() Value {
+	vm.stackTop--
+	return vm.stack[vm.stackTop]
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[vm.stackTop-1-distance]
+}
+
+// token reports the source token of the instruction currently executing.
+func (vm *VM) token() scanner.Token {
+	frame := &vm.frames[vm.frameCount-1]
+	return frame.closure.Function.Chunk.Token(frame.ip - 1)
+}
+
+func (vm *VM) runtimeError(format string, args ...any) error {
+	return interpreter.NewRuntimeError(vm.token(), format, args...)
+}
+
+// stackTrace returns the call stack, innermost first.
+func (vm *VM) stackTrace() []interpreter.StackFrame {
+	stack := make([]interpreter.StackFrame, 0, vm.frameCount)
+	for idx := vm.frameCount - 1; idx >= 0; idx-- {
+		frame := &vm.frames[idx]
+		function := frame.closure.Function
+		name := function.Name
+		if name == "" {
+			name = "script"
+		}
+		stack = append(stack, interpreter.StackFrame{
+			Function: name,
+			File:     function.File,
+			Token:    function.Chunk.Token(frame.ip - 1),
+		})
+	}
+	return stack
+}
+
+// annotate records the call stack on an uncaught runtime error before the
+// frames are discarded.
+func (vm *VM) annotate(err error) {
+	var rerr *interpreter.RuntimeError
+	if errors.As(err, &rerr) && rerr.Stack == nil {
+		rerr.Stack = vm.stackTrace()
+	}
+}
+
+func (vm *VM) resetStack() {
+	vm.stackTop = 0
+	vm.frameCount = 0
+	vm.openUpvalues = nil
+	vm.handlers = nil
+}